@@ -0,0 +1,121 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// healthChecker tracks the informer cache sync, the freshness of its watch and the validity of the
+// serving certificate to determine the readiness of the webhook
+type healthChecker struct {
+	// lastSeen is the unix nano time of the last list, watch start or watch event of the informer
+	lastSeen int64
+	// shuttingDown is set to 1 once the webhook starts shutting down
+	shuttingDown int32
+
+	synced       cache.InformerSynced
+	maxStaleness time.Duration
+	certificate  func() (*x509.Certificate, error)
+}
+
+// newHealthChecker returns a health checker considering the informer watch stale after maxStaleness
+// without any list, watch start or watch event
+func newHealthChecker(maxStaleness time.Duration) *healthChecker {
+	return &healthChecker{
+		maxStaleness: maxStaleness,
+	}
+}
+
+// touch records the informer activity
+func (hc *healthChecker) touch() {
+	atomic.StoreInt64(&hc.lastSeen, time.Now().UnixNano())
+}
+
+// instrument wraps the list watcher to record its activity as the informer watch freshness
+func (hc *healthChecker) instrument(lw *cache.ListWatch) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			list, err := lw.List(options)
+			if err == nil {
+				hc.touch()
+			}
+			return list, err
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			hc.touch()
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				hc.touch()
+				return event, true
+			}), nil
+		},
+		DisableChunking: lw.DisableChunking,
+	}
+}
+
+// shutdown marks the webhook as shutting down, taking it out of the service endpoints
+func (hc *healthChecker) shutdown() {
+	atomic.StoreInt32(&hc.shuttingDown, 1)
+}
+
+// ready returns nil when the informer cache is synced, its watch is fresh and the serving certificate is
+// valid, otherwise the reason why the webhook is not ready
+func (hc *healthChecker) ready() error {
+	if atomic.LoadInt32(&hc.shuttingDown) == 1 {
+		return fmt.Errorf("Webhook is shutting down")
+	}
+
+	if hc.synced == nil || !hc.synced() {
+		return fmt.Errorf("Ingress informer cache is not synced")
+	}
+
+	lastSeen := time.Unix(0, atomic.LoadInt64(&hc.lastSeen))
+	if staleness := time.Since(lastSeen); staleness > hc.maxStaleness {
+		return fmt.Errorf("Ingress informer watch is stale, last activity %s ago", staleness.Round(time.Second))
+	}
+
+	if hc.certificate != nil {
+		cert, err := hc.certificate()
+		if err != nil {
+			return fmt.Errorf("Serving certificate is not available: %s", err.Error())
+		}
+		now := time.Now()
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return fmt.Errorf("Serving certificate is not valid, valid from %s until %s", cert.NotBefore,
+				cert.NotAfter)
+		}
+	}
+	return nil
+}
+
+// healthzHandler serves the /healthz liveness response which is 200 as long as the server is serving
+func healthzHandler(rw http.ResponseWriter, req *http.Request) {
+	log.Debugf("Serving %s %s request for client: %s", req.Method, req.URL.Path, req.RemoteAddr)
+	io.WriteString(rw, "OK")
+}
+
+// readyzHandler serves the /readyz readiness response which is 200 when the webhook is able to take
+// admission decisions on an up-to-date cache, 503 otherwise
+func readyzHandler(rw http.ResponseWriter, req *http.Request) {
+	log.Debugf("Serving %s %s request for client: %s", req.Method, req.URL.Path, req.RemoteAddr)
+	if err := health.ready(); err != nil {
+		log.Warnf("Readiness check failed: %s", err.Error())
+		http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	io.WriteString(rw, "OK")
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newReadyHealthChecker() *healthChecker {
+	hc := newHealthChecker(time.Minute)
+	hc.synced = func() bool { return true }
+	hc.certificate = func() (*x509.Certificate, error) {
+		return &x509.Certificate{
+			NotBefore: time.Now().Add(-time.Hour),
+			NotAfter:  time.Now().Add(time.Hour),
+		}, nil
+	}
+	hc.touch()
+	return hc
+}
+
+func TestHealthCheckerReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(hc *healthChecker)
+		expected string
+	}{
+		{
+			"should be ready when synced, fresh and with a valid certificate",
+			func(hc *healthChecker) {},
+			"",
+		},
+		{
+			"should not be ready when the cache is not synced",
+			func(hc *healthChecker) {
+				hc.synced = func() bool { return false }
+			},
+			"Ingress informer cache is not synced",
+		},
+		{
+			"should not be ready when the watch is stale",
+			func(hc *healthChecker) {
+				hc.lastSeen = time.Now().Add(-2 * time.Minute).UnixNano()
+			},
+			"Ingress informer watch is stale",
+		},
+		{
+			"should not be ready when the certificate expired",
+			func(hc *healthChecker) {
+				hc.certificate = func() (*x509.Certificate, error) {
+					return &x509.Certificate{
+						NotBefore: time.Now().Add(-2 * time.Hour),
+						NotAfter:  time.Now().Add(-time.Hour),
+					}, nil
+				}
+			},
+			"Serving certificate is not valid",
+		},
+		{
+			"should not be ready when the certificate is not available",
+			func(hc *healthChecker) {
+				hc.certificate = func() (*x509.Certificate, error) {
+					return nil, errors.New("test")
+				}
+			},
+			"Serving certificate is not available: test",
+		},
+		{
+			"should not be ready when shutting down",
+			func(hc *healthChecker) {
+				hc.shutdown()
+			},
+			"Webhook is shutting down",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hc := newReadyHealthChecker()
+			test.modify(hc)
+			err := hc.ready()
+			if test.expected == "" {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Contains(t, err.Error(), test.expected, test.name)
+			}
+		})
+	}
+}
+
+func TestHealthCheckerInstrument(t *testing.T) {
+	hc := newHealthChecker(time.Minute)
+	fakeWatcher := watch.NewFake()
+	lw := hc.instrument(&cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			return nil, errors.New("test")
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			return fakeWatcher, nil
+		},
+	})
+
+	_, err := lw.List(v1.ListOptions{})
+	assert.NotNil(t, err, "should return the list error")
+	assert.Equal(t, int64(0), hc.lastSeen, "should not record a failed list")
+
+	w, err := lw.Watch(v1.ListOptions{})
+	assert.Nil(t, err, "err should be nil")
+	watchStarted := hc.lastSeen
+	assert.NotEqual(t, int64(0), watchStarted, "should record the watch start")
+
+	go fakeWatcher.Add(&runtime.Unknown{})
+	<-w.ResultChan()
+	assert.True(t, hc.lastSeen >= watchStarted, "should record the watch event")
+	w.Stop()
+}
+
+func TestReadyzHandler(t *testing.T) {
+	health = newReadyHealthChecker()
+	rw := httptest.NewRecorder()
+	readyzHandler(rw, httptest.NewRequest("GET", "http://localhost:8080/readyz", nil))
+	assert.Equal(t, http.StatusOK, rw.Code, "/readyz should return 200 when ready")
+
+	health.synced = func() bool { return false }
+	rw = httptest.NewRecorder()
+	readyzHandler(rw, httptest.NewRequest("GET", "http://localhost:8080/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code, "/readyz should return 503 when not ready")
+}
+
+func TestShutdown(t *testing.T) {
+	health = newReadyHealthChecker()
+	drainPeriod := *shutdownDrainPeriod
+	*shutdownDrainPeriod = 20 * time.Millisecond
+	defer func() { *shutdownDrainPeriod = drainPeriod }()
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+	}))
+	srv.Start()
+	defer srv.Close()
+
+	completed := make(chan int)
+	go func() {
+		resp, err := http.Get(srv.URL)
+		if assert.Nil(t, err, "should complete the in-flight request") {
+			completed <- resp.StatusCode
+		}
+	}()
+	<-started
+
+	stop := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NotNil(t, health.ready(), "should not be ready while draining")
+		close(release)
+	}()
+	shutdown(srv.Config, stop)
+
+	assert.Equal(t, http.StatusOK, <-completed)
+	_, open := <-stop
+	assert.False(t, open, "should stop the informers")
+}
+
+func TestHealthzHandler200(t *testing.T) {
+	rw := httptest.NewRecorder()
+	healthzHandler(rw, httptest.NewRequest("GET", "http://localhost:8080/healthz", nil))
+	assert.Equal(t, http.StatusOK, rw.Code, "/healthz should return 200")
+}
@@ -4,42 +4,184 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
+
+	admv1 "k8s.io/api/admission/v1"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
-	"k8s.io/api/extensions/v1beta1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// cause types naming the claimed host and path along with their owner on the claim rejections
+	causeTypeHost  v1.CauseType = "ingressclaim.yahoo.io/host"
+	causeTypePath  v1.CauseType = "ingressclaim.yahoo.io/path"
+	causeTypeOwner v1.CauseType = "ingressclaim.yahoo.io/owner"
+)
+
 var (
-	ingressResourceType = v1.GroupVersionResource{
-		Group:    "extensions",
-		Version:  "v1beta1",
-		Resource: "ingresses",
+	// admissionReviewVersions lists the AdmissionReview api versions the webhook is able to respond to
+	admissionReviewVersions = map[string]bool{
+		admv1.SchemeGroupVersion.String():      true,
+		admv1beta1.SchemeGroupVersion.String(): true,
+	}
+
+	// ingressResourceTypes maps every served Ingress version to a constructor of its typed object
+	ingressResourceTypes = map[v1.GroupVersionResource]func() interface{}{
+		{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}: func() interface{} {
+			return &extv1beta1.Ingress{}
+		},
+		{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"}: func() interface{} {
+			return &networkingv1beta1.Ingress{}
+		},
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}: func() interface{} {
+			return &networkingv1.Ingress{}
+		},
 	}
 )
 
-// writeResponse writes the ingressReviewStatus object to the response body
-func writeResponse(rw http.ResponseWriter, admRequest *admv1beta1.AdmissionRequest, allowed bool, errorMsg string) {
+// decodeIngress decodes the raw object of the given resource version and converts it into the internal
+// Ingress model shared by the providers
+func decodeIngress(resource v1.GroupVersionResource, raw []byte) (*networkingv1.Ingress, error) {
+	newObject, ok := ingressResourceTypes[resource]
+	if !ok {
+		return nil, fmt.Errorf("Incoming resource: %v is not an Ingress resource", resource)
+	}
+	obj := newObject()
+	if err := json.Unmarshal(raw, obj); err != nil {
+		return nil, err
+	}
+	return provider.ToIngress(obj)
+}
+
+// decodeRequestIngress decodes the raw object, or old object, of the given resource version on the admission
+// review request along with its metadata into the internal Ingress model
+func decodeRequestIngress(resource v1.GroupVersionResource, raw []byte, object string) (*networkingv1.Ingress,
+	error) {
+	ingress, err := decodeIngress(resource, raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode the raw %s resource on the admission review request into an "+
+			"Ingress resource: %s", object, err.Error())
+	}
+	log.Debugf("Decoded Ingress spec %v", ingress)
+
+	if err := json.Unmarshal(raw, &ingress.ObjectMeta); err != nil {
+		return nil, fmt.Errorf("Failed to parse the Ingress metadata from the raw %s resource on the admission "+
+			"review request: %s", object, err.Error())
+	}
+	log.Debugf("Decoded Ingress metadata %v", ingress.ObjectMeta)
+	return ingress, nil
+}
+
+// badRequestStatus returns the Status of an admission review request that cannot be processed
+func badRequestStatus(message string) *v1.Status {
+	return &v1.Status{
+		Status:  v1.StatusFailure,
+		Code:    http.StatusBadRequest,
+		Reason:  v1.StatusReasonBadRequest,
+		Message: message,
+	}
+}
+
+// rejectionStatus returns the Status of the named ingress of the resource rejected with the given message for
+// the error err. The typed provider rejections are mapped to a conflict Status when any of them is a conflict,
+// to a forbidden Status for the protected domains, otherwise to an invalid Status, with the causes of every
+// rejection naming the offending field, the claimed host and path and their owner. Any other error is an
+// internal error.
+func rejectionStatus(message string, err error, name string, resource v1.GroupVersionResource) *v1.Status {
+	status := &v1.Status{
+		Status:  v1.StatusFailure,
+		Code:    http.StatusInternalServerError,
+		Reason:  v1.StatusReasonInternalError,
+		Message: message,
+	}
+	rejections := provider.Rejections(err)
+	if len(rejections) == 0 {
+		return status
+	}
+
+	status.Code, status.Reason = http.StatusUnprocessableEntity, v1.StatusReasonInvalid
+	for _, rejection := range rejections {
+		if rejection.Reason == provider.ReasonProtectedHost {
+			status.Code, status.Reason = http.StatusForbidden, v1.StatusReasonForbidden
+		}
+	}
+	status.Details = &v1.StatusDetails{
+		Name:  name,
+		Group: resource.Group,
+		Kind:  resource.Resource,
+	}
+	for _, rejection := range rejections {
+		if rejection.IsConflict() {
+			status.Code, status.Reason = http.StatusConflict, v1.StatusReasonConflict
+		}
+		status.Details.Causes = append(status.Details.Causes, v1.StatusCause{
+			Type:    v1.CauseType(rejection.Reason),
+			Message: rejection.Message,
+			Field:   rejection.Field,
+		})
+		for _, cause := range []v1.StatusCause{
+			{Type: causeTypeHost, Message: rejection.Host, Field: rejection.Field},
+			{Type: causeTypePath, Message: rejection.Path, Field: rejection.Field},
+			{Type: causeTypeOwner, Message: rejection.Owner, Field: rejection.Field},
+		} {
+			if cause.Message != "" {
+				status.Details.Causes = append(status.Details.Causes, cause)
+			}
+		}
+	}
+	return status
+}
+
+// writeResponse writes the ingressReviewStatus object to the response body. The response is versioned after
+// the incoming AdmissionReview and echoes the request UID, as required by the admission.k8s.io/v1 api.
+// The result Status of a denied request and the warnings are returned to the client along with the response.
+func writeResponse(rw http.ResponseWriter, review *admv1.AdmissionReview, allowed bool, result *v1.Status,
+	warnings ...string) {
+	admRequest := review.Request
 	log.Infof("Responding Allowed: %t for %s on Ingress: %s/%s by user: %s", allowed,
 		admRequest.Operation,
 		admRequest.Namespace,
 		admRequest.Name,
 		admRequest.UserInfo.Username)
 
+	if result == nil {
+		result = &v1.Status{
+			Status: v1.StatusSuccess,
+			Code:   http.StatusOK,
+		}
+	}
 	if !allowed {
-		log.Errorf("Rejection reason: %s", errorMsg)
+		log.Errorf("Rejection reason: %s", result.Message)
+	}
+	for _, warning := range warnings {
+		log.Warnf("Admission warning: %s", warning)
+	}
+
+	apiVersion := review.APIVersion
+	if apiVersion == "" {
+		apiVersion = admv1beta1.SchemeGroupVersion.String()
 	}
 
-	admReview := admv1beta1.AdmissionReview{
-		Response: &admv1beta1.AdmissionResponse{
-			Allowed: allowed,
-			Result: &v1.Status{
-				Reason: v1.StatusReason(errorMsg),
-			},
+	admReview := admv1.AdmissionReview{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: apiVersion,
+			Kind:       "AdmissionReview",
+		},
+		Response: &admv1.AdmissionResponse{
+			UID:      admRequest.UID,
+			Allowed:  allowed,
+			Result:   result,
+			Warnings: warnings,
 		},
 	}
 
@@ -49,13 +191,16 @@ func writeResponse(rw http.ResponseWriter, admRequest *admv1beta1.AdmissionReque
 		io.WriteString(rw, "Error occurred while encoding the admission review status into json: "+err.Error())
 		return
 	}
+	rw.Header().Set("Content-Type", "application/json")
 	rw.Write(body.Bytes())
 }
 
-// webhookHandler serves all the CREATE and UPDATE admission webhook calls on ingress resources and returns the
-// AdmissionReviewSpec with the admission status determined based on the validation and domain claims check results
+// webhookHandler serves all the CREATE, UPDATE and DELETE admission webhook calls on ingress resources and returns
+// the AdmissionReviewSpec with the admission status determined based on the validation, domain claims and
+// protected domains check results
 func webhookHandler(rw http.ResponseWriter, req *http.Request) {
 	log.Infof("Serving %s %s request for client: %s", req.Method, req.URL.Path, req.RemoteAddr)
+	start := time.Now()
 
 	if req.Method != http.MethodPost {
 		http.Error(rw, fmt.Sprintf("Incoming request method %s is not supported, only POST is supported",
@@ -68,15 +213,31 @@ func webhookHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	admReview := admv1beta1.AdmissionReview{
-		Request:  &admv1beta1.AdmissionRequest{},
-		Response: &admv1beta1.AdmissionResponse{},
+	// admission.k8s.io/v1 and v1beta1 AdmissionReviews share the same schema, decode both into the v1 types
+	admReview := admv1.AdmissionReview{
+		Request:  &admv1.AdmissionRequest{},
+		Response: &admv1.AdmissionResponse{},
 	}
+
+	// respond records the admission decision metrics before writing the response
+	providerName := provider.None
+	respond := func(allowed bool, reason string, result *v1.Status, warnings ...string) {
+		recordAdmission(providerName, string(admReview.Request.Operation), allowed, reason, start)
+		writeResponse(rw, &admReview, allowed, result, warnings...)
+	}
+
 	err := json.NewDecoder(req.Body).Decode(&admReview)
 	if err != nil {
+		decodeFailures.WithLabelValues(objectReview).Inc()
 		errorMsg := fmt.Sprintf("Failed to decode the request body json into an AdmissionReview resource: %s",
 			err.Error())
-		writeResponse(rw, admReview.Request, false, errorMsg)
+		respond(false, reasonDecode, badRequestStatus(errorMsg))
+		return
+	}
+
+	if admReview.APIVersion != "" && !admissionReviewVersions[admReview.APIVersion] {
+		http.Error(rw, fmt.Sprintf("AdmissionReview api version %s is not supported", admReview.APIVersion),
+			http.StatusBadRequest)
 		return
 	}
 	log.Debugf("Incoming AdmissionReview for resource: %v, kind: %v", admReview.Request.Resource, admReview.Kind)
@@ -85,54 +246,139 @@ func webhookHandler(rw http.ResponseWriter, req *http.Request) {
 	if *admitAll == true {
 		log.Warnf("admitAll flag is set to true. Allowing Ingress admission review request to pass through " +
 			"without validation.")
-		writeResponse(rw, admReview.Request, true, "")
+		respond(true, reasonAdmitAll, nil)
 		return
 	}
 
-	if admReview.Request.Resource != ingressResourceType {
+	if _, ok := ingressResourceTypes[admReview.Request.Resource]; !ok {
 		errorMsg := fmt.Sprintf("Incoming resource: %v is not an Ingress resource", admReview.Request.Resource)
-		writeResponse(rw, admReview.Request, false, errorMsg)
+		respond(false, reasonResource, badRequestStatus(errorMsg))
 		return
 	}
 
-	// decode the incoming object into an ingress resource
-	ingress := &v1beta1.Ingress{}
-	if err := json.Unmarshal(admReview.Request.Object.Raw, ingress); err != nil {
-		errorMsg := fmt.Sprintf("Failed to decode the raw object resource on the admission review request "+
-			"into an Ingress resource: %s", err.Error())
-		writeResponse(rw, admReview.Request, false, errorMsg)
+	// decode the incoming object into an ingress resource of the internal model, the deleted ingress is the
+	// old object of a DELETE
+	operation := admReview.Request.Operation
+	object, raw := "object", admReview.Request.Object.Raw
+	if operation == admv1.Delete {
+		object, raw = "old object", admReview.Request.OldObject.Raw
+	}
+	ingress, err := decodeRequestIngress(admReview.Request.Resource, raw, object)
+	if err != nil {
+		decodeFailures.WithLabelValues(objectIngress).Inc()
+		respond(false, reasonDecode, badRequestStatus(err.Error()))
 		return
 	}
-	log.Debugf("Decoded Ingress spec %v", ingress)
 
-	if err := json.Unmarshal(admReview.Request.Object.Raw, &ingress.ObjectMeta); err != nil {
-		errorMsg := fmt.Sprintf("Failed to parse the Ingress metadata from the raw object resource on the "+
-			"admission review request: %s", err.Error())
-		writeResponse(rw, admReview.Request, false, errorMsg)
-		return
+	// decode the ingress being updated to check the domains dropped by the update
+	var oldIngress *networkingv1.Ingress
+	if operation == admv1.Update && len(admReview.Request.OldObject.Raw) > 0 {
+		oldIngress, err = decodeRequestIngress(admReview.Request.Resource, admReview.Request.OldObject.Raw,
+			"old object")
+		if err != nil {
+			decodeFailures.WithLabelValues(objectIngress).Inc()
+			respond(false, reasonDecode, badRequestStatus(err.Error()))
+			return
+		}
 	}
-	log.Debugf("Decoded Ingress metadata %v", ingress.ObjectMeta)
 
 	// retrieve the ingress claim provider implementation for the current resource
 	p := helper.GetProvider(ingress)
+	providerName = p.Name()
+
+	// the claim status written back by the claim status reporter leaves the claims of the ingress unchanged
+	if oldIngress != nil && helper.IsClaimStatusUpdate(oldIngress, ingress) {
+		log.Debugf("Ingress %s in namespace %s only updates its claim status.", ingress.Name, ingress.Namespace)
+		respond(true, reasonStatus, nil)
+		return
+	}
+
+	// the non-fatal findings of the provider are returned as warnings along with the response
+	warnings := []string{}
+	if operation != admv1.Delete {
+		warnings = p.GetWarnings(ingress)
+	}
+
+	// in audit mode the failed checks are turned into warnings and the ingress is admitted
+	audited := false
+	mode := helper.GetEnforcementMode(providerName, ingress.Namespace)
+	deny := func(reason string, errorMsg string, err error) bool {
+		if mode != provider.EnforcementAudit {
+			respond(false, reason, rejectionStatus(errorMsg, err, ingress.Name, admReview.Request.Resource), warnings...)
+			return true
+		}
+		log.Warnf("Audit mode, admitting Ingress %s in namespace %s that would be denied: %s", ingress.Name,
+			ingress.Namespace, errorMsg)
+		auditDenials.WithLabelValues(providerName, reason).Inc()
+		warnings = append(warnings, "Audit mode, the ingress would be denied: "+errorMsg)
+		audited = true
+		return false
+	}
+
+	// a DELETE is only checked against the protected domains of the deleted ingress
+	if operation == admv1.Delete {
+		if err := helper.ValidateRemoval(ingress, nil); err != nil {
+			if deny(reasonProtected, err.Error(), err) {
+				return
+			}
+		}
+		if audited {
+			respond(true, reasonAudit, nil, warnings...)
+			return
+		}
+		log.Infof("Ingress %s in namespace %s removes no protected domains.", ingress.Name, ingress.Namespace)
+		respond(true, reasonNone, nil, warnings...)
+		return
+	}
 
 	// perform the ingress claim provider specific validation checks
 	err = p.ValidateSemantics(ingress)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Ingress validation checks failed: %s", err.Error())
-		writeResponse(rw, admReview.Request, false, errorMsg)
-		return
+		if deny(reasonValidation, errorMsg, err) {
+			return
+		}
+	}
+
+	// check that an update does not drop protected domains, before the domains are claimed
+	if oldIngress != nil {
+		if err := helper.ValidateRemoval(oldIngress, ingress); err != nil {
+			if deny(reasonProtected, err.Error(), err) {
+				return
+			}
+		}
 	}
 
-	// perform the domain claims check with the ingress provider
-	err = p.ValidateDomainClaims(ingress)
+	// perform the domain claims check with the ingress provider, only enforced on the domains added by an update
+	// with the diff update validation, a dry run leaving the domains unclaimed
+	// the claim leases are bounded by the request, so that a slow apiserver cannot hold the admission past the
+	// webhook timeout
+	ctx, cancel := context.WithTimeout(req.Context(), *claimLeaseTimeout)
+	defer cancel()
+	dryRun := admReview.Request.DryRun != nil && *admReview.Request.DryRun
+	switch {
+	case oldIngress != nil && helper.GetUpdateValidation() == provider.UpdateValidationDiff:
+		var updateWarnings []string
+		updateWarnings, err = helper.ValidateUpdatedDomainClaims(ctx, p, oldIngress, ingress, dryRun)
+		warnings = append(warnings, updateWarnings...)
+	case dryRun:
+		err = helper.ValidateDryRunDomainClaims(ctx, p, ingress)
+	default:
+		err = p.ValidateDomainClaims(ctx, ingress)
+	}
 	if err != nil {
-		writeResponse(rw, admReview.Request, false, err.Error())
+		if deny(reasonClaim, err.Error(), err) {
+			return
+		}
+	}
+
+	if audited {
+		respond(true, reasonAudit, nil, warnings...)
 		return
 	}
 
 	log.Infof("Ingress %s in namespace %s contains no duplicate domains.", ingress.Name, ingress.Namespace)
-	writeResponse(rw, admReview.Request, true, "")
+	respond(true, reasonNone, nil, warnings...)
 }
 
 // statusHandler serves the /status.html response which is always 200.
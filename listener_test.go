@@ -11,15 +11,20 @@ import (
 	"net/http/httptest"
 	"os/user"
 	"testing"
+	"time"
 
 	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	admv1 "k8s.io/api/admission/v1"
 	admv1beta1 "k8s.io/api/admission/v1beta1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/cache"
 )
@@ -105,11 +110,11 @@ func constructPostBody(admReview *admv1beta1.AdmissionReview) io.Reader {
 
 func TestAllowedWriteResponse(t *testing.T) {
 	rw := httptest.NewRecorder()
-	review := &admv1beta1.AdmissionReview{
-		Request:  &admv1beta1.AdmissionRequest{},
-		Response: &admv1beta1.AdmissionResponse{},
+	review := &admv1.AdmissionReview{
+		Request:  &admv1.AdmissionRequest{},
+		Response: &admv1.AdmissionResponse{},
 	}
-	writeResponse(rw, review.Request, true, "")
+	writeResponse(rw, review, true, nil)
 
 	admReview := getAdmissionReview(rw)
 
@@ -117,23 +122,25 @@ func TestAllowedWriteResponse(t *testing.T) {
 		Response: &admv1beta1.AdmissionResponse{
 			Allowed: true,
 			Result: &v1.Status{
-				Reason: v1.StatusReason(""),
+				Status: v1.StatusSuccess,
+				Code:   http.StatusOK,
 			},
 		},
 	}
+	assert.True(t, admReview.Response.Allowed, "writeResponse should write Allowed: true for AdmissionReviewStatus")
 	assert.Equal(t,
-		expectedAdmReview.Response.Result.Status,
-		admReview.Response.Result.Status,
-		"writeResponse should write Allowed: true for AdmissionReviewStatus")
+		expectedAdmReview.Response.Result,
+		admReview.Response.Result,
+		"writeResponse should write a Success status for AdmissionReviewStatus")
 }
 
 func TestNotAllowedWriteResponse(t *testing.T) {
 	rw := httptest.NewRecorder()
-	review := &admv1beta1.AdmissionReview{
-		Request:  &admv1beta1.AdmissionRequest{},
-		Response: &admv1beta1.AdmissionResponse{},
+	review := &admv1.AdmissionReview{
+		Request:  &admv1.AdmissionRequest{},
+		Response: &admv1.AdmissionResponse{},
 	}
-	writeResponse(rw, review.Request, false, "Duplicate domain exists.")
+	writeResponse(rw, review, false, badRequestStatus("Duplicate domain exists."))
 
 	admReview := getAdmissionReview(rw)
 
@@ -141,14 +148,63 @@ func TestNotAllowedWriteResponse(t *testing.T) {
 		Response: &admv1beta1.AdmissionResponse{
 			Allowed: false,
 			Result: &v1.Status{
-				Reason: v1.StatusReason("Duplicate domain exists."),
+				Status:  v1.StatusFailure,
+				Code:    http.StatusBadRequest,
+				Reason:  v1.StatusReasonBadRequest,
+				Message: "Duplicate domain exists.",
 			},
 		},
 	}
+	assert.False(t, admReview.Response.Allowed, "writeResponse should write Allowed: false for AdmissionReviewStatus")
 	assert.Equal(t,
-		expectedAdmReview.Response.Result.Status,
-		admReview.Response.Result.Status,
-		"writeResponse should write Allowed: false for AdmissionReviewStatus")
+		expectedAdmReview.Response.Result,
+		admReview.Response.Result,
+		"writeResponse should write the Failure status for AdmissionReviewStatus")
+}
+
+func TestVersionedWriteResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		expected   string
+	}{
+		{
+			"should respond with admission.k8s.io/v1 to a v1 review",
+			"admission.k8s.io/v1",
+			"admission.k8s.io/v1",
+		},
+		{
+			"should respond with admission.k8s.io/v1beta1 to a v1beta1 review",
+			"admission.k8s.io/v1beta1",
+			"admission.k8s.io/v1beta1",
+		},
+		{
+			"should respond with admission.k8s.io/v1beta1 to an unversioned review",
+			"",
+			"admission.k8s.io/v1beta1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			review := &admv1.AdmissionReview{
+				TypeMeta: v1.TypeMeta{
+					APIVersion: test.apiVersion,
+					Kind:       "AdmissionReview",
+				},
+				Request: &admv1.AdmissionRequest{
+					UID: types.UID("test-uid"),
+				},
+			}
+			writeResponse(rw, review, true, nil)
+
+			admReview := getAdmissionReview(rw)
+
+			assert.Equal(t, test.expected, admReview.APIVersion, test.name)
+			assert.Equal(t, "AdmissionReview", admReview.Kind, test.name)
+			assert.Equal(t, types.UID("test-uid"), admReview.Response.UID, test.name)
+		})
+	}
 }
 
 func TestWrongMethodWebhookHandler(t *testing.T) {
@@ -181,7 +237,7 @@ func TestWrongReqBodyWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should fail if request doesn't have a body")
-	assert.Contains(t, admReview.Response.Result.Reason, "Failed to decode the request body json into an "+
+	assert.Contains(t, admReview.Response.Result.Message, "Failed to decode the request body json into an "+
 		"AdmissionReview resource: ")
 }
 
@@ -201,6 +257,41 @@ func TestAdmitAllWebhookHandler(t *testing.T) {
 	*admitAll = false
 }
 
+func TestUnsupportedReviewVersionWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.APIVersion = "admission.k8s.io/v2"
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestAdmissionV1WebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.APIVersion = "admission.k8s.io/v1"
+	testSpec.Kind = "AdmissionReview"
+	testSpec.Request.UID = types.UID("test-v1-uid")
+	setIngressOnAdmissionReview(testSpec, templateIngress.DeepCopy())
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	helper.SetIndexer(indexer)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.True(t, admReview.Response.Allowed, "should approve a valid ingress on an admission.k8s.io/v1 review")
+	assert.Equal(t, "admission.k8s.io/v1", admReview.APIVersion)
+	assert.Equal(t, types.UID("test-v1-uid"), admReview.Response.UID)
+}
+
 func TestIngressResourceTypeWebhookHandler(t *testing.T) {
 	rw := httptest.NewRecorder()
 
@@ -220,7 +311,7 @@ func TestIngressResourceTypeWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should reject if the resource is not Ingress type")
-	assert.Contains(t, admReview.Response.Result.Reason, "Incoming resource: { v1 pods} is not an Ingress resource")
+	assert.Contains(t, admReview.Response.Result.Message, "Incoming resource: { v1 pods} is not an Ingress resource")
 }
 
 func TestIngressDecodeWebhookHandler(t *testing.T) {
@@ -239,7 +330,7 @@ func TestIngressDecodeWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should reject if the review object cannot be decoded to an Ingress")
-	assert.Contains(t, admReview.Response.Result.Reason, "Failed to decode the raw object resource on the "+
+	assert.Contains(t, admReview.Response.Result.Message, "Failed to decode the raw object resource on the "+
 		"admission review request into an Ingress resource: ")
 }
 
@@ -257,7 +348,17 @@ func TestIngressValidationWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should reject if the Ingress validation checks fail")
-	assert.Contains(t, admReview.Response.Result.Reason, "Ingress validation checks failed: ")
+	assert.Contains(t, admReview.Response.Result.Message, "Ingress validation checks failed: ")
+	assert.Equal(t, int32(http.StatusUnprocessableEntity), admReview.Response.Result.Code,
+		"should reject an invalid Ingress with an unprocessable entity code")
+	assert.Equal(t, v1.StatusReasonInvalid, admReview.Response.Result.Reason, "should reject with an invalid reason")
+	assert.Equal(t, []v1.StatusCause{
+		{
+			Type:    v1.CauseType(provider.ReasonMissingAnnotation),
+			Message: "Ingress test-ingress in namespace test-namespace does not have a ports annotation specified.",
+			Field:   "metadata.annotations[ports]",
+		},
+	}, admReview.Response.Result.Details.Causes, "should name the missing annotation")
 }
 
 func TestNoDuplicateDomainsWebhookHandler(t *testing.T) {
@@ -332,10 +433,38 @@ func TestDuplicateDomainsInSameNamespaceWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should reject if duplicate domain exists even within the same ns")
-	assert.Contains(t, admReview.Response.Result.Reason, "Domain app-domain-default.company.com already "+
+	assert.Contains(t, admReview.Response.Result.Message, "Domain app-domain-default.company.com already "+
 		"exists. Ingress second-ingress in namespace test-namespace owns this domain.")
 }
 
+func TestSharedDomainsInSameNamespaceWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testIngress := templateIngress.DeepCopy()
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Annotations[string(provider.DefaultDomain)] = "app-domain-default.company.com"
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "test-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+	helper.SetClaimPolicy(provider.ATS, provider.ClaimPolicy{Owner: provider.OwnerNamespace})
+
+	setIngressOnAdmissionReview(testSpec, testIngress)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.True(t, admReview.Response.Allowed, "should approve a duplicate domain within the same ns when the "+
+		"namespace owns the claims")
+	helper.SetClaimPolicy(provider.ATS, provider.ClaimPolicy{Owner: provider.OwnerIngress})
+}
+
 func TestDuplicateDomainsWebhookHandler(t *testing.T) {
 	rw := httptest.NewRecorder()
 
@@ -361,8 +490,391 @@ func TestDuplicateDomainsWebhookHandler(t *testing.T) {
 	admReview := getAdmissionReview(rw)
 
 	assert.False(t, admReview.Response.Allowed, "should reject if duplicate domain exists on any other ns/ingress")
-	assert.Contains(t, admReview.Response.Result.Reason, "Domain app-domain-alias.company.com already "+
+	assert.Contains(t, admReview.Response.Result.Message, "Domain app-domain-alias.company.com already "+
 		"exists. Ingress second-ingress in namespace second-namespace owns this domain.")
+	assert.Equal(t, int32(http.StatusConflict), admReview.Response.Result.Code, "should reject with a conflict code")
+	assert.Equal(t, v1.StatusReasonConflict, admReview.Response.Result.Reason, "should reject with a conflict reason")
+	assert.Equal(t, &v1.StatusDetails{
+		Name:  testIngress.Name,
+		Group: testSpec.Request.Resource.Group,
+		Kind:  testSpec.Request.Resource.Resource,
+		Causes: []v1.StatusCause{
+			{
+				Type:    v1.CauseType(provider.ReasonDomainConflict),
+				Message: admReview.Response.Result.Message,
+				Field:   "metadata.annotations[aliases]",
+			},
+			{
+				Type:    causeTypeHost,
+				Message: "app-domain-alias.company.com",
+				Field:   "metadata.annotations[aliases]",
+			},
+			{
+				Type:    causeTypeOwner,
+				Message: "second-namespace/second-ingress",
+				Field:   "metadata.annotations[aliases]",
+			},
+		},
+	}, admReview.Response.Result.Details, "should name the conflicting field, host and owner")
+}
+
+func TestAllDuplicateDomainsWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testIngress := templateIngress.DeepCopy()
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Annotations[string(provider.DefaultDomain)] = "app-domain-test.company.com"
+	testIngress2.Annotations[string(provider.Aliases)] = ""
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+	testIngress3 := templateIngress.DeepCopy()
+	testIngress3.Annotations[string(provider.DefaultDomain)] = "app-domain-alias.company.com"
+	testIngress3.Annotations[string(provider.Aliases)] = ""
+	testIngress3.Name = "third-ingress"
+	testIngress3.Namespace = "third-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	indexer.Add(testIngress3)
+	helper.SetIndexer(indexer)
+
+	setIngressOnAdmissionReview(testSpec, testIngress)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.False(t, admReview.Response.Allowed, "should reject if duplicate domains exist on other ns/ingresses")
+	assert.Equal(t, "Domain app-domain-test.company.com already exists. Ingress second-ingress in namespace "+
+		"second-namespace owns this domain. Domain app-domain-alias.company.com already exists. Ingress "+
+		"third-ingress in namespace third-namespace owns this domain.", admReview.Response.Result.Message,
+		"should report all the duplicate domains in a single denial")
+	assert.Equal(t, v1.StatusReasonConflict, admReview.Response.Result.Reason, "should reject with a conflict reason")
+	owners := []string{}
+	for _, cause := range admReview.Response.Result.Details.Causes {
+		if cause.Type == causeTypeOwner {
+			owners = append(owners, cause.Field+"="+cause.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"metadata.annotations[default_domain]=second-namespace/second-ingress",
+		"metadata.annotations[aliases]=third-namespace/third-ingress",
+	}, owners, "should name the owner of every duplicate domain in the causes")
+}
+
+func TestDuplicateDomainsAcrossVersionsWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.Request.Resource = v1.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1",
+		Resource: "ingresses",
+	}
+	testIngress := &networkingv1.Ingress{
+		ObjectMeta: templateIngress.ObjectMeta,
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: "test-svc",
+					Port: networkingv1.ServiceBackendPort{Number: 80},
+				},
+			},
+		},
+	}
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Annotations[string(provider.DefaultDomain)] = "default-app-domain.company.com"
+	testIngress2.Annotations[string(provider.Aliases)] = "app-domain-alias.company.com"
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+
+	ing := new(bytes.Buffer)
+	if err := json.NewEncoder(ing).Encode(testIngress); err != nil {
+		panic(err.Error())
+	}
+	testSpec.Request.Object.Raw = ing.Bytes()
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.False(t, admReview.Response.Allowed, "should reject a networking.k8s.io/v1 ingress claiming a domain "+
+		"owned by an extensions/v1beta1 ingress")
+	assert.Contains(t, admReview.Response.Result.Message, "Domain app-domain-alias.company.com already "+
+		"exists. Ingress second-ingress in namespace second-namespace owns this domain.")
+}
+
+func TestAuditModeWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testIngress := templateIngress.DeepCopy()
+	testIngress.Annotations[string(provider.Ports)] = ""
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+	helper.SetEnforcementModes(provider.EnforcementEnforce, nil,
+		map[string]provider.EnforcementMode{"test-namespace": provider.EnforcementAudit})
+	defer helper.SetEnforcementModes(provider.EnforcementEnforce, nil, nil)
+
+	audited := auditDenials.WithLabelValues(provider.ATS, reasonClaim)
+	auditedBefore := testutil.ToFloat64(audited)
+
+	setIngressOnAdmissionReview(testSpec, testIngress)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.True(t, admReview.Response.Allowed, "should admit the would-be denials in audit mode")
+	if assert.Len(t, admReview.Response.Warnings, 2, "should warn about the validation and the claim failures") {
+		assert.Contains(t, admReview.Response.Warnings[0], "Audit mode, the ingress would be denied: Ingress "+
+			"validation checks failed: ")
+		assert.Contains(t, admReview.Response.Warnings[1], "Domain app-domain-test.company.com already exists.")
+	}
+	assert.Equal(t, auditedBefore+1, testutil.ToFloat64(audited), "should count the audited claim denial")
+}
+
+func TestWarningsWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testIngress := templateIngress.DeepCopy()
+	testIngress.Annotations[string(provider.Aliases)] = "app-domain-test.company.com"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	helper.SetIndexer(indexer)
+
+	setIngressOnAdmissionReview(testSpec, testIngress)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	admReview := getAdmissionReview(rw)
+
+	assert.True(t, admReview.Response.Allowed, "should admit an ingress with soft policy violations")
+	assert.Equal(t, []string{"Alias app-domain-test.company.com duplicates the default_domain annotation."},
+		admReview.Response.Warnings, "should return the provider findings as warnings")
+}
+
+func TestProtectedDomainsWebhookHandler(t *testing.T) {
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	helper.SetIndexer(indexer)
+
+	protectedIngress := templateIngress.DeepCopy()
+	protectedIngress.Annotations[string(provider.Protected)] = "true"
+	updatedIngress := protectedIngress.DeepCopy()
+	updatedIngress.Annotations[string(provider.Aliases)] = "app-domain-default.company.com"
+
+	encode := func(ingress *v1beta1.Ingress) []byte {
+		raw, err := json.Marshal(ingress)
+		if err != nil {
+			panic(err.Error())
+		}
+		return raw
+	}
+	tests := []struct {
+		name       string
+		operation  admv1beta1.Operation
+		object     *v1beta1.Ingress
+		oldObject  *v1beta1.Ingress
+		allowed    bool
+		rejections int
+	}{
+		{
+			"should reject the deletion of a protected ingress",
+			admv1beta1.Delete,
+			nil,
+			protectedIngress,
+			false,
+			3,
+		},
+		{
+			"should admit the deletion of an unprotected ingress",
+			admv1beta1.Delete,
+			nil,
+			templateIngress,
+			true,
+			0,
+		},
+		{
+			"should reject an update dropping a domain of a protected ingress",
+			admv1beta1.Update,
+			updatedIngress,
+			protectedIngress,
+			false,
+			1,
+		},
+		{
+			"should admit an update keeping the domains of a protected ingress",
+			admv1beta1.Update,
+			protectedIngress,
+			protectedIngress,
+			true,
+			0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			testSpec := templateAdmReview.DeepCopy()
+			testSpec.Request.Operation = test.operation
+			testSpec.Request.Object.Raw = nil
+			if test.object != nil {
+				testSpec.Request.Object.Raw = encode(test.object)
+			}
+			testSpec.Request.OldObject.Raw = encode(test.oldObject)
+
+			req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+			webhookHandler(rw, req)
+
+			admReview := getAdmissionReview(rw)
+
+			assert.Equal(t, test.allowed, admReview.Response.Allowed, test.name)
+			if !test.allowed {
+				assert.Equal(t, int32(http.StatusForbidden), admReview.Response.Result.Code, test.name)
+				assert.Equal(t, v1.StatusReasonForbidden, admReview.Response.Result.Reason, test.name)
+				causes := 0
+				for _, cause := range admReview.Response.Result.Details.Causes {
+					if cause.Type == v1.CauseType(provider.ReasonProtectedHost) {
+						causes++
+					}
+				}
+				assert.Equal(t, test.rejections, causes, "should report every protected domain")
+			}
+		})
+	}
+}
+
+func TestDiffUpdateValidationWebhookHandler(t *testing.T) {
+	testIngress := templateIngress.DeepCopy()
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Annotations[string(provider.DefaultDomain)] = "default-app-domain.company.com"
+	testIngress2.Annotations[string(provider.Aliases)] = "app-domain-alias.company.com"
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+
+	// an unrelated edit of an ingress admitted with a conflict on app-domain-alias.company.com
+	updatedIngress := testIngress.DeepCopy()
+	updatedIngress.Spec.Backend.ServiceName = "updated-svc"
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.Request.Operation = admv1beta1.Update
+	setIngressOnAdmissionReview(testSpec, updatedIngress)
+	oldObject, err := json.Marshal(testIngress)
+	if err != nil {
+		panic(err.Error())
+	}
+	testSpec.Request.OldObject.Raw = oldObject
+
+	rw := httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	admReview := getAdmissionReview(rw)
+	assert.False(t, admReview.Response.Allowed, "should reject the pre-existing conflict with the full validation")
+
+	helper.SetUpdateValidation(provider.UpdateValidationDiff)
+	defer helper.SetUpdateValidation(provider.UpdateValidationFull)
+
+	rw = httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	admReview = getAdmissionReview(rw)
+	assert.True(t, admReview.Response.Allowed, "should admit an unrelated edit with the diff validation")
+	assert.Equal(t, []string{"Pre-existing conflict not enforced on update: Domain app-domain-alias.company.com " +
+		"already exists. Ingress second-ingress in namespace second-namespace owns this domain."},
+		admReview.Response.Warnings, "should warn about the pre-existing conflict")
+
+	updatedIngress.Annotations[string(provider.Aliases)] += ",default-app-domain.company.com"
+	setIngressOnAdmissionReview(testSpec, updatedIngress)
+	rw = httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	admReview = getAdmissionReview(rw)
+	assert.False(t, admReview.Response.Allowed, "should reject a conflicting domain added by the update")
+	assert.Equal(t, "Domain default-app-domain.company.com already exists. Ingress second-ingress in namespace "+
+		"second-namespace owns this domain.", admReview.Response.Result.Message,
+		"should only enforce the domains added by the update")
+}
+
+func TestDryRunWebhookHandler(t *testing.T) {
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	helper.SetIndexer(indexer)
+	helper.SetPendingClaimTTL(time.Minute)
+	defer helper.SetPendingClaimTTL(0)
+
+	dryRun := true
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.Request.DryRun = &dryRun
+	setIngressOnAdmissionReview(testSpec, templateIngress.DeepCopy())
+	rw := httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	assert.True(t, getAdmissionReview(rw).Response.Allowed, "should admit the dry run of an unclaimed domain")
+
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+	testSpec = templateAdmReview.DeepCopy()
+	setIngressOnAdmissionReview(testSpec, testIngress2)
+	rw = httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	assert.True(t, getAdmissionReview(rw).Response.Allowed, "should not reserve the domains of a dry run")
+}
+
+func TestClaimStatusUpdateWebhookHandler(t *testing.T) {
+	testIngress := templateIngress.DeepCopy()
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+
+	// the claim status reported on a legacy duplicate of the domains of the second ingress
+	reportedIngress := testIngress.DeepCopy()
+	reportedIngress.Annotations[string(provider.ClaimStatus)] = `{"provider":"ats","hosts":` +
+		`["default-app-domain.company.com"]}`
+	testSpec := templateAdmReview.DeepCopy()
+	testSpec.Request.Operation = admv1beta1.Update
+	setIngressOnAdmissionReview(testSpec, reportedIngress)
+	oldObject, err := json.Marshal(testIngress)
+	if err != nil {
+		panic(err.Error())
+	}
+	testSpec.Request.OldObject.Raw = oldObject
+
+	rw := httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	admReview := getAdmissionReview(rw)
+	assert.True(t, admReview.Response.Allowed, "should admit the claim status update of a legacy duplicate")
+
+	reportedIngress.Spec.Backend.ServiceName = "updated-svc"
+	setIngressOnAdmissionReview(testSpec, reportedIngress)
+	rw = httptest.NewRecorder()
+	webhookHandler(rw, httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec)))
+	admReview = getAdmissionReview(rw)
+	assert.False(t, admReview.Response.Allowed, "should check an update changing more than the claim status")
 }
 
 func TestStatusHandler200(t *testing.T) {
@@ -3,23 +3,32 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
 	"github.com/yahoo/k8s-ingress-claim/pkg/util"
 
 	"github.com/Sirupsen/logrus"
-	"k8s.io/api/extensions/v1beta1"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -34,11 +43,92 @@ var (
 	clientCAFile  = flag.String("clientCAFile", "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt", "The cluster root CA that signs the apiserver cert")
 	clientAuth    = flag.Bool("clientAuth", false, "True to verify client cert/auth during TLS handshake.")
 	admitAll      = flag.Bool("admitAll", false, "True to admit all ingress without validation.")
+	ingressAPI    = flag.String("ingressAPIVersion", "networking.k8s.io/v1", "The Ingress API group/version "+
+		"watched by the informer, one of: networking.k8s.io/v1, networking.k8s.io/v1beta1, extensions/v1beta1.")
+	watchClasses = flag.Bool("watchIngressClasses", true, "True to watch networking.k8s.io/v1 IngressClass "+
+		"resources to resolve the ingress class names and the cluster default class into providers.")
+	classControllers = flag.String("ingressClassControllers", "", "Comma separated list of provider=controller "+
+		"pairs mapping IngressClass controllers to providers, in addition to the controllers registered by "+
+		"the providers.")
+	wildcardPolicy = flag.String("wildcardPolicy", string(provider.WildcardExclusive), "How wildcard domain "+
+		"claims interact with the domains beneath them, one of: exclusive, specific, exact.")
+	claimGranularity = flag.String("claimGranularity", "", "Comma separated list of provider=granularity pairs "+
+		"setting the claim granularity of the providers, one of: host, path. Providers default to host.")
+	claimOwner = flag.String("claimOwner", "", "Comma separated list of provider=owner pairs setting who owns "+
+		"the domains claimed by the ingresses of the providers, one of: ingress, namespace, label:<key>. "+
+		"Providers default to ingress.")
+	pendingClaimTTL = flag.Duration("pendingClaimTTL", provider.DefaultPendingClaimTTL, "The time the domains "+
+		"of an admitted ingress stay reserved until the informer observes the ingress, 0 to disable the "+
+		"reservations.")
+	claimLeaseDuration = flag.Duration("claimLeaseDuration", 0, "The time the domains of an admitted ingress "+
+		"stay leased to its owner through coordination.k8s.io Leases, for the replicas to agree on the first "+
+		"claim before their caches observe the ingress. 0 to disable the leases with a single replica.")
+	claimLeaseNamespace = flag.String("claimLeaseNamespace", "default", "The namespace of the claim Leases.")
+	claimLeaseTimeout   = flag.Duration("claimLeaseTimeout", 5*time.Second, "The time an admission waits for "+
+		"the claim Leases of its domains before being rejected, below the timeoutSeconds of the webhook.")
+	maxWatchStaleness = flag.Duration("maxWatchStaleness", 15*time.Minute, "The maximum time without any "+
+		"list, watch start or watch event on the Ingress informer before the webhook is reported not ready.")
+	registerWebhook = flag.Bool("registerWebhook", false, "True to create and update the "+
+		"ValidatingWebhookConfiguration of the webhook and keep its caBundle in sync with the serving CA.")
+	webhookName = flag.String("webhookName", "k8s-ingress-claim", "The name of the registered "+
+		"ValidatingWebhookConfiguration.")
+	webhookService = flag.String("webhookService", "default/k8s-ingress-claim", "The namespace/name of the "+
+		"Service of the registered webhook.")
+	webhookCAFile = flag.String("webhookCAFile", "", "The CA file that signs the server cert, registered as the "+
+		"webhook caBundle. A CA and a server cert for the webhook Service are generated and shared by the "+
+		"replicas through the webhookCertSecret when empty.")
+	webhookCertSecret = flag.String("webhookCertSecret", "k8s-ingress-claim-generated-certs", "The Secret of the "+
+		"webhook Service namespace sharing the generated CA, server cert and key across the replicas when no "+
+		"webhookCAFile is given.")
+	webhookCertDir = flag.String("webhookCertDir", "/tmp/k8s-ingress-claim", "The writable directory the "+
+		"generated server cert and key are written into, served instead of the certFile and keyFile, when no "+
+		"webhookCAFile is given.")
+	webhookOperations = flag.String("webhookOperations", "CREATE,UPDATE", "Comma separated list of the "+
+		"Ingress operations sent to the registered webhook, of: CREATE, UPDATE, DELETE, *.")
+	webhookFailurePolicy = flag.String("webhookFailurePolicy", "Fail", "The failure policy of the registered "+
+		"webhook, one of: Fail, Ignore.")
+	webhookNamespaceSelector = flag.String("webhookNamespaceSelector", "", "The label selector of the "+
+		"namespaces whose ingresses are sent to the registered webhook, all namespaces when empty.")
+	certReloadInterval = flag.Duration("certReloadInterval", time.Minute, "The interval of polling the cert, "+
+		"key and client CA files for changes to reload them, 0 to disable the reloading.")
+	shutdownDrainPeriod = flag.Duration("shutdownDrainPeriod", 5*time.Second, "The time to keep serving while "+
+		"reported not ready on shutdown, for the webhook to be removed from the service endpoints.")
+	shutdownTimeout = flag.Duration("shutdownTimeout", 10*time.Second, "The maximum time to wait for the "+
+		"in-flight admission requests to complete on shutdown.")
+	domainClaims = flag.Bool("domainClaims", false, "True to watch the DomainClaim custom resources reserving "+
+		"domains for namespaces, the DomainClaim CRD must be installed.")
+	enforcementMode = flag.String("enforcementMode", string(provider.EnforcementEnforce), "What happens to the "+
+		"ingresses failing the checks, one of: enforce, audit. Audit admits them, the would-be denials are "+
+		"logged, counted and returned as warnings.")
+	providerEnforcementMode = flag.String("providerEnforcementMode", "", "Comma separated list of "+
+		"provider=mode pairs overriding the enforcementMode for the providers.")
+	namespaceEnforcementMode = flag.String("namespaceEnforcementMode", "", "Comma separated list of "+
+		"namespace=mode pairs overriding the enforcementMode for the namespaces, over the provider modes.")
+	providers = flag.String("providers", "", "Comma separated list of the enabled providers in their order of "+
+		"precedence. All the registered providers in registration order when empty.")
+	providerClasses = flag.String("providerClasses", "", "Comma separated list of class=provider pairs mapping "+
+		"the ingress class names without an IngressClass resource to providers. Class names default to the "+
+		"provider names.")
+	defaultProvider = flag.String("defaultProvider", "", "The provider serving the ingresses without a class "+
+		"when the cluster has no default IngressClass, none to skip their checks. The first enabled provider "+
+		"when empty.")
+	claimGroups = flag.String("claimGroups", "", "Comma separated list of provider=group pairs checking the "+
+		"claims of the providers of a group against each other, * standing for all the other providers. "+
+		"Providers check their claims on their own by default.")
+	updateValidation = flag.String("updateValidation", string(provider.UpdateValidationFull), "Which domains "+
+		"of an updated ingress the domain claim checks are enforced on, one of: full, diff. Diff only enforces "+
+		"the checks on the domains added by the update and warns about the conflicts of the other domains.")
+	protectedHosts = flag.String("protectedHosts", "", "Comma separated list of the hosts, or wildcards covering "+
+		"them, that no ingress update may drop and no ingress deletion may remove.")
+	claimStatusInterval = flag.Duration("claimStatusInterval", 0, "The interval of writing the claimed hosts "+
+		"and the conflicts detected in the cache back to the claim-status annotation of the ingresses, 0 to "+
+		"disable the claim status reporting.")
 
 	indexer  cache.Indexer
 	informer cache.Controller
 
 	helper = provider.GetHelper()
+	health *healthChecker
 
 	log *logrus.Logger
 )
@@ -62,60 +152,237 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// create the ingress watcher
-	ingressListWatcher := cache.NewListWatchFromClient(clientset.ExtensionsV1beta1().RESTClient(),
+	// create the ingress watcher for the configured api version
+	ingressRESTClient, ingressObject, err := ingressAPIClient(clientset, *ingressAPI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ingressListWatcher := cache.NewListWatchFromClient(ingressRESTClient,
 		"ingresses",
 		v1.NamespaceAll,
 		fields.Everything())
 
-	// create the indexer & informer framework
+	// track the informer watch freshness for the readiness checks
+	health = newHealthChecker(*maxWatchStaleness)
+	ingressListWatcher = health.instrument(ingressListWatcher)
+
+	// enable the configured providers, each one indexing the domains it would claim on the ingresses of any class
+	if err = configureProviders(); err != nil {
+		log.Fatal(err)
+	}
+	indexers := helper.GetIndexers()
+	providerNames := []string{}
+	for _, p := range helper.GetProviders() {
+		providerNames = append(providerNames, p.Name())
+	}
+
+	// create the indexer & informer framework, releasing the pending claims of the observed ingresses
 	indexer, informer = cache.NewIndexerInformer(ingressListWatcher,
-		&v1beta1.Ingress{},
+		ingressObject,
 		0,
-		cache.ResourceEventHandlerFuncs{},
-		cache.Indexers{
-			provider.ATS:   helper.GetProviderByName(provider.ATS).DomainsIndexFunc,
-			provider.Istio: helper.GetProviderByName(provider.Istio).DomainsIndexFunc,
-		})
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: helper.ObserveIngress,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				helper.ObserveIngress(newObj)
+			},
+			DeleteFunc: helper.ObserveIngress,
+		},
+		indexers)
 
 	helper.SetIndexer(indexer)
+	helper.SetPendingClaimTTL(*pendingClaimTTL)
 
-	// start the informer before calling handlers (dependency: indexer)
+	// configure the domain claim policies
+	watchNamespaces, err := configureClaimPolicies()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// lease the admitted domains to settle the concurrent claims across the replicas
+	var leases *provider.ClaimLeases
+	if *claimLeaseDuration > 0 {
+		leases = provider.NewClaimLeases(clientset.CoordinationV1().Leases(*claimLeaseNamespace),
+			*claimLeaseDuration)
+		helper.SetClaimLeases(leases)
+	}
+
+	// configure the enforcement modes
+	if err = configureEnforcementModes(); err != nil {
+		log.Fatal(err)
+	}
+
+	// map the IngressClass controllers to the providers, overriding the registered controllers
+	if *classControllers != "" {
+		controllers, err := util.ParseKeyValues(*classControllers)
+		if err != nil {
+			log.Fatalf("Unable to parse the ingressClassControllers flag: %s", err.Error())
+		}
+		providerControllers := map[string]string{}
+		for name, controller := range controllers {
+			providerControllers[controller] = name
+		}
+		helper.SetControllers(providerControllers)
+	}
+
+	// create the IngressClass watcher & informer
+	var classInformer cache.Controller
+	if *watchClasses {
+		var classStore cache.Store
+		classListWatcher := cache.NewListWatchFromClient(clientset.NetworkingV1().RESTClient(),
+			"ingressclasses",
+			v1.NamespaceAll,
+			fields.Everything())
+		classStore, classInformer = cache.NewInformer(classListWatcher,
+			&networkingv1.IngressClass{},
+			0,
+			cache.ResourceEventHandlerFuncs{})
+		helper.SetIngressClassStore(classStore)
+	}
+
+	// create the Namespace watcher & informer when the claims are owned by namespace labels
+	var namespaceInformer cache.Controller
+	if watchNamespaces {
+		var namespaceStore cache.Store
+		namespaceListWatcher := cache.NewListWatchFromClient(clientset.CoreV1().RESTClient(),
+			"namespaces",
+			v1.NamespaceAll,
+			fields.Everything())
+		namespaceStore, namespaceInformer = cache.NewInformer(namespaceListWatcher,
+			&corev1.Namespace{},
+			0,
+			cache.ResourceEventHandlerFuncs{})
+		helper.SetNamespaceStore(namespaceStore)
+	}
+
+	// create the DomainClaim watcher & informer through the dynamic client
+	var claimInformer cache.Controller
+	if *domainClaims {
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		claimClient := dynamicClient.Resource(provider.DomainClaimResource)
+		claimListWatcher := &cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				return claimClient.List(context.TODO(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				return claimClient.Watch(context.TODO(), options)
+			},
+		}
+		var claimIndexer cache.Indexer
+		claimIndexer, claimInformer = cache.NewIndexerInformer(claimListWatcher,
+			&unstructured.Unstructured{},
+			0,
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{
+				provider.DomainClaimIndex: provider.DomainClaimsIndexFunc,
+			})
+		helper.SetDomainClaimIndexer(claimIndexer)
+	}
+
+	// sync the IngressClass informer before starting the Ingress informer, for the classes of the listed ingresses
+	// to resolve as soon as the ingresses are synced
 	stop := make(chan struct{})
+	if classInformer != nil {
+		log.Info("Starting IngressClass informer...")
+		go classInformer.Run(stop)
+		if !cache.WaitForCacheSync(stop, classInformer.HasSynced) {
+			log.Fatal(fmt.Errorf("Timed out waiting for the IngressClass cache to sync"))
+		}
+	}
+
+	// start the informer before calling handlers (dependency: indexer)
 	log.Info("Starting Ingress informer...")
 	go informer.Run(stop)
+	synced := []cache.InformerSynced{informer.HasSynced}
+	if claimInformer != nil {
+		log.Info("Starting DomainClaim informer...")
+		go claimInformer.Run(stop)
+		synced = append(synced, claimInformer.HasSynced)
+	}
+	if namespaceInformer != nil {
+		log.Info("Starting Namespace informer...")
+		go namespaceInformer.Run(stop)
+		synced = append(synced, namespaceInformer.HasSynced)
+	}
 
 	// wait for all involved cache to be synced, before processing items from the queue is started
 	log.Debugf("Waiting for the cache to be synced...")
-	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+	if !cache.WaitForCacheSync(stop, synced...) {
 		log.Fatal(fmt.Errorf("Timed out waiting for the cache to sync"))
 	}
 
+	// fail fast when two providers serve the same ingress classes
+	if err = helper.CheckProviderOverlaps(); err != nil {
+		log.Fatal(err)
+	}
+
+	health.synced = informer.HasSynced
+
+	// register the admission and informer cache metrics
+	registerMetrics(indexer, providerNames)
+
+	// report the claim status of the cached ingresses on their annotations
+	if *claimStatusInterval > 0 {
+		go newClaimStatusReporter(ingressRESTClient, indexer).run(*claimStatusInterval, stop)
+	}
+
+	// collect the expired claim leases every lease duration
+	if leases != nil {
+		go collectClaimLeases(leases, *claimLeaseDuration, stop)
+	}
+
 	// add the serving path handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/status.html", statusHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/", webhookHandler)
 
-	// load the https server cert and key
-	xcert, err := tls.LoadX509KeyPair(*httpsCertFile, *httpsKeyFile)
-	if err != nil {
-		log.Fatalf("Unable to read the server cert and/or key file: %s", err.Error())
+	// create the webhook registrar, generating the https server cert/key shared by the replicas when no webhook
+	// CA is given
+	var registrar *webhookRegistrar
+	certFile, keyFile := *httpsCertFile, *httpsKeyFile
+	if *registerWebhook {
+		registrar, err = newWebhookRegistrar(clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations(),
+			*webhookName, *webhookService, *webhookOperations, *webhookFailurePolicy, *webhookNamespaceSelector)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *webhookCAFile != "" {
+			registrar.loadWebhookCA(*webhookCAFile)
+		} else {
+			certFile, keyFile, err = registrar.generateWebhookCerts(
+				clientset.CoreV1().Secrets(registrar.serviceNamespace), *webhookCertSecret, *webhookCertDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
-	// load the cluster CA that signs the client(apiserver) cert
-	caCert, err := ioutil.ReadFile(*clientCAFile)
+	// load the https server cert/key and the cluster CA that signs the client(apiserver) cert, and reload them
+	// on rotation of the mounted secrets
+	certs, err := util.NewCertReloader(certFile, keyFile, *clientCAFile)
 	if err != nil {
-		log.Fatalf("Unable to read the client CA cert file: %s", err.Error())
+		log.Fatal(err)
+	}
+	health.certificate = certs.Certificate
+	if *certReloadInterval > 0 {
+		go certs.Watch(*certReloadInterval, stop, func(err error) {
+			if err != nil {
+				log.Errorf("Unable to reload the server cert, key and client CA files: %s", err.Error())
+				return
+			}
+			log.Info("Reloaded the server cert, key and client CA files")
+		})
 	}
 
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-
-	// create the TLS config for the https server
+	// create the TLS config for the https server, serving the current cert and client CA on every handshake
 	tlsConfig := &tls.Config{
-		RootCAs:      caCertPool,
-		Certificates: []tls.Certificate{xcert},
-		ClientCAs:    caCertPool,
+		GetCertificate: certs.GetCertificate,
+		ClientCAs:      certs.ClientCAs(),
 	}
 
 	// enable client(apiserver) certificate verification if --clientAuth=true
@@ -123,6 +390,8 @@ func main() {
 		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
 	}
 
+	tlsConfig.GetConfigForClient = certs.GetConfigForClient(tlsConfig)
+
 	// create the https server object
 	srv := &http.Server{
 		Addr:      ":" + *port,
@@ -133,21 +402,181 @@ func main() {
 	// start the https server
 	go func() {
 		err = srv.ListenAndServeTLS("", "")
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
 	log.Infof("HTTPS server listening on port:%s with ClientAuthEnabled:%t ", *port, *clientAuth)
 
+	// register the webhook once serving and keep its caBundle in sync on rotation
+	if registrar != nil {
+		if err := registrar.sync(); err != nil {
+			log.Fatal(err)
+		}
+		if *certReloadInterval > 0 {
+			go registrar.run(*certReloadInterval, stop)
+		}
+	}
+
 	// graceful shutdown..
 	signalChan := make(chan os.Signal, 2)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	<-signalChan
+	log.Infof("Shutdown signal received, draining for %s...", *shutdownDrainPeriod)
+	shutdown(srv, stop)
+}
+
+// shutdown reports the webhook not ready, keeps serving for the drain period so that the webhook is removed
+// from the service endpoints, waits for the in-flight requests to complete and then stops the informers
+func shutdown(srv *http.Server, stop chan struct{}) {
+	health.shutdown()
+	time.Sleep(*shutdownDrainPeriod)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Errorf("Unable to complete the in-flight requests: %s", err.Error())
+	}
+	close(stop)
+	log.Info("Shutdown complete, exiting...")
+}
+
+// configureProviders enables the providers, maps the ingress class names to providers and sets the claim groups
+// of the providers along with the protected hosts from the command line flags
+func configureProviders() error {
+	names := []string{}
+	for _, name := range strings.Split(*providers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := helper.SetProviders(names); err != nil {
+		return fmt.Errorf("Unable to enable the providers: %s", err.Error())
+	}
+	if err := helper.SetDefaultProvider(*defaultProvider); err != nil {
+		return err
+	}
+	classNames, err := util.ParseKeyValues(*providerClasses)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the providerClasses flag: %s", err.Error())
+	}
+	helper.SetClassNames(classNames)
+	groups, err := util.ParseKeyValues(*claimGroups)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the claimGroups flag: %s", err.Error())
+	}
+	helper.SetClaimGroups(groups)
+	helper.SetProtectedHosts(strings.Split(*protectedHosts, ","))
+	return nil
+}
+
+// configureClaimPolicies sets the domain claim policies of the providers from the command line flags and
+// returns whether any policy needs the namespace labels
+func configureClaimPolicies() (bool, error) {
+	wildcard, err := provider.ParseWildcardPolicy(*wildcardPolicy)
+	if err != nil {
+		return false, err
+	}
+	granularities, err := util.ParseKeyValues(*claimGranularity)
+	if err != nil {
+		return false, fmt.Errorf("Unable to parse the claimGranularity flag: %s", err.Error())
+	}
+	owners, err := util.ParseKeyValues(*claimOwner)
+	if err != nil {
+		return false, fmt.Errorf("Unable to parse the claimOwner flag: %s", err.Error())
+	}
+
+	watchNamespaces := false
+	for _, p := range helper.GetProviders() {
+		name := p.Name()
+		policy := provider.ClaimPolicy{
+			Wildcard:    wildcard,
+			Granularity: provider.GranularityHost,
+			Owner:       provider.OwnerIngress,
+		}
+		if value, exists := granularities[name]; exists {
+			if policy.Granularity, err = provider.ParseClaimGranularity(value); err != nil {
+				return false, err
+			}
+		}
+		if value, exists := owners[name]; exists {
+			if policy.Owner, policy.OwnerLabel, err = provider.ParseClaimOwner(value); err != nil {
+				return false, err
+			}
+		}
+		watchNamespaces = watchNamespaces || policy.Owner == provider.OwnerLabel
+		helper.SetClaimPolicy(name, policy)
+	}
+	return watchNamespaces, nil
+}
+
+// configureEnforcementModes sets the enforcement modes of the providers and namespaces along with the update
+// validation from the command line flags
+func configureEnforcementModes() error {
+	mode, err := provider.ParseEnforcementMode(*enforcementMode)
+	if err != nil {
+		return err
+	}
+	providerModes, err := parseEnforcementModes(*providerEnforcementMode)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the providerEnforcementMode flag: %s", err.Error())
+	}
+	namespaceModes, err := parseEnforcementModes(*namespaceEnforcementMode)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the namespaceEnforcementMode flag: %s", err.Error())
+	}
+	helper.SetEnforcementModes(mode, providerModes, namespaceModes)
+
+	validation, err := provider.ParseUpdateValidation(*updateValidation)
+	if err != nil {
+		return err
+	}
+	helper.SetUpdateValidation(validation)
+	return nil
+}
+
+// parseEnforcementModes parses a comma separated list of key=mode pairs into the enforcement modes by key
+func parseEnforcementModes(s string) (map[string]provider.EnforcementMode, error) {
+	values, err := util.ParseKeyValues(s)
+	if err != nil {
+		return nil, err
+	}
+	modes := map[string]provider.EnforcementMode{}
+	for key, value := range values {
+		if modes[key], err = provider.ParseEnforcementMode(value); err != nil {
+			return nil, err
+		}
+	}
+	return modes, nil
+}
+
+// ingressAPIClient returns the REST client and the typed object for the given Ingress api group/version
+func ingressAPIClient(clientset *kubernetes.Clientset, apiVersion string) (rest.Interface, runtime.Object, error) {
+	switch apiVersion {
+	case "networking.k8s.io/v1":
+		return clientset.NetworkingV1().RESTClient(), &networkingv1.Ingress{}, nil
+	case "networking.k8s.io/v1beta1":
+		return clientset.NetworkingV1beta1().RESTClient(), &networkingv1beta1.Ingress{}, nil
+	case "extensions/v1beta1":
+		return clientset.ExtensionsV1beta1().RESTClient(), &extv1beta1.Ingress{}, nil
+	}
+	return nil, nil, fmt.Errorf("Unsupported Ingress api version: %s", apiVersion)
+}
+
+// collectClaimLeases deletes the expired claim leases every interval until stop is closed
+func collectClaimLeases(leases *provider.ClaimLeases, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-signalChan:
-			log.Printf("Shutdown signal received, exiting...")
-			close(stop)
-			os.Exit(0)
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := leases.Collect(ctx); err != nil {
+				log.Error(err)
+			}
+			cancel()
 		}
 	}
 }
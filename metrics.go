@@ -0,0 +1,92 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	metricsNamespace = "ingress_claim"
+
+	// rejection reason categories of the admission decisions
+	reasonNone       = "none"
+	reasonAdmitAll   = "admit_all"
+	reasonDecode     = "decode"
+	reasonResource   = "resource"
+	reasonValidation = "validation"
+	reasonClaim      = "claim"
+	reasonAudit      = "audit"
+	reasonProtected  = "protected"
+	reasonStatus     = "status"
+
+	// objects failing to decode
+	objectReview  = "review"
+	objectIngress = "ingress"
+)
+
+var (
+	admissionDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_decisions_total",
+		Help:      "Number of admission decisions by provider, operation, decision and rejection reason category.",
+	}, []string{"provider", "operation", "decision", "reason"})
+
+	admissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_duration_seconds",
+		Help:      "Latency of the admission review requests by provider and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "operation"})
+
+	decodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decode_failures_total",
+		Help:      "Number of admission review requests that failed to decode by decoded object.",
+	}, []string{"object"})
+
+	auditDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_denials_total",
+		Help:      "Number of would-be denials admitted in audit mode by provider and rejection reason category.",
+	}, []string{"provider", "reason"})
+)
+
+// registerMetrics registers the admission metrics along with the informer cache size gauges of the
+// given provider indexes
+func registerMetrics(indexer cache.Indexer, indexes []string) {
+	prometheus.MustRegister(admissionDecisions, admissionDuration, decodeFailures, auditDenials)
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "cached_ingresses",
+		Help:      "Number of ingresses in the informer cache.",
+	}, func() float64 {
+		return float64(len(indexer.ListKeys()))
+	}))
+
+	for _, index := range indexes {
+		index := index
+		prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "indexed_domains",
+			Help:        "Number of domains in the informer cache index of the provider, for the ingresses of any class.",
+			ConstLabels: prometheus.Labels{"provider": index},
+		}, func() float64 {
+			return float64(len(indexer.ListIndexFuncValues(index)))
+		}))
+	}
+}
+
+// recordAdmission records the admission decision and the latency of the request started at start
+func recordAdmission(provider string, operation string, allowed bool, reason string, start time.Time) {
+	decision := "denied"
+	if allowed {
+		decision = "allowed"
+	}
+	admissionDecisions.WithLabelValues(provider, operation, decision, reason).Inc()
+	admissionDuration.WithLabelValues(provider, operation).Observe(time.Since(start).Seconds())
+}
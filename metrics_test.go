@@ -0,0 +1,64 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestRecordAdmission(t *testing.T) {
+	allowed := admissionDecisions.WithLabelValues("test", "CREATE", "allowed", reasonNone)
+	denied := admissionDecisions.WithLabelValues("test", "CREATE", "denied", reasonClaim)
+	allowedBefore, deniedBefore := testutil.ToFloat64(allowed), testutil.ToFloat64(denied)
+
+	recordAdmission("test", "CREATE", true, reasonNone, time.Now())
+	recordAdmission("test", "CREATE", false, reasonClaim, time.Now())
+	recordAdmission("test", "CREATE", false, reasonClaim, time.Now())
+
+	assert.Equal(t, allowedBefore+1, testutil.ToFloat64(allowed), "should count the allowed decision")
+	assert.Equal(t, deniedBefore+2, testutil.ToFloat64(denied), "should count the denied decisions")
+}
+
+func TestDuplicateDomainsMetricsWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	testSpec := templateAdmReview.DeepCopy()
+	testIngress := templateIngress.DeepCopy()
+	testIngress2 := templateIngress.DeepCopy()
+	testIngress2.Name = "second-ingress"
+	testIngress2.Namespace = "second-namespace"
+
+	indexer = cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.ATS: helper.GetProviderByName(provider.ATS).DomainsIndexFunc})
+	indexer.Add(testIngress2)
+	helper.SetIndexer(indexer)
+
+	setIngressOnAdmissionReview(testSpec, testIngress)
+
+	denied := admissionDecisions.WithLabelValues(provider.ATS, "CREATE", "denied", reasonClaim)
+	deniedBefore := testutil.ToFloat64(denied)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", constructPostBody(testSpec))
+	webhookHandler(rw, req)
+
+	assert.Equal(t, deniedBefore+1, testutil.ToFloat64(denied), "should count the claim rejection")
+}
+
+func TestDecodeFailureMetricsWebhookHandler(t *testing.T) {
+	rw := httptest.NewRecorder()
+	failures := decodeFailures.WithLabelValues(objectReview)
+	failuresBefore := testutil.ToFloat64(failures)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/", nil)
+	webhookHandler(rw, req)
+
+	assert.Equal(t, failuresBefore+1, testutil.ToFloat64(failures), "should count the decode failure")
+}
@@ -3,10 +3,10 @@
 package provider
 
 import (
-	"errors"
+	"context"
 	"strings"
 
-	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 const (
@@ -24,6 +24,11 @@ const (
 
 type ats struct{}
 
+// init registers the provider along with its IngressClass controller
+func init() {
+	Register(NewATSProvider(), ATSController)
+}
+
 // NewATSProvider returns a new ATS provider ref that implements Provider interface
 func NewATSProvider() Provider {
 	return &ats{}
@@ -34,14 +39,14 @@ func (ts *ats) Name() string {
 	return ATS
 }
 
-// ServesIngress checks if the given ingress falls under ATS provider class
-func (ts *ats) ServesIngress(ingress *v1beta1.Ingress) bool {
-	class, exists := ingress.Annotations[string(IngressClass)]
-	return !exists || class == ATS
+// ServesIngress checks if the given ingress falls under ATS provider class, ingresses without a class
+// are served by ATS unless the cluster default IngressClass belongs to another provider
+func (ts *ats) ServesIngress(ingress *networkingv1.Ingress) bool {
+	return helper.resolveProviderName(ingress) == ATS
 }
 
 // GetDomains returns the list of hosts associated with rules for the ATS ingress
-func (ts *ats) GetDomains(ingress *v1beta1.Ingress) []string {
+func (ts *ats) GetDomains(ingress *networkingv1.Ingress) []string {
 	domains := []string{}
 	if ts.ServesIngress(ingress) {
 		domains = helper.appendNonEmpty(domains, ts.getDefaultDomain(ingress))
@@ -53,9 +58,9 @@ func (ts *ats) GetDomains(ingress *v1beta1.Ingress) []string {
 // DomainsIndexFunc returns the list of hosts claimed by the given ATS ingress
 func (ts *ats) DomainsIndexFunc(obj interface{}) ([]string, error) {
 	domains := []string{}
-	ingress, ok := obj.(*v1beta1.Ingress)
-	if !ok {
-		return nil, errors.New("Resource is not an Ingress kind.")
+	ingress, err := ToIngress(obj)
+	if err != nil {
+		return nil, err
 	}
 	if ts.ServesIngress(ingress) {
 		return ts.GetDomains(ingress), nil
@@ -64,37 +69,85 @@ func (ts *ats) DomainsIndexFunc(obj interface{}) ([]string, error) {
 }
 
 // ValidateSemantics performs ATS specific validation checks
-func (ts *ats) ValidateSemantics(ingress *v1beta1.Ingress) error {
+func (ts *ats) ValidateSemantics(ingress *networkingv1.Ingress) error {
 	if ts.ServesIngress(ingress) {
-		if ingress.Spec.Backend == nil {
-			return errors.New("Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
-				" does not have a default backend specified.")
+		if ingress.Spec.DefaultBackend == nil {
+			return &RejectionError{
+				Reason: ReasonInvalidBackend,
+				Message: "Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
+					" does not have a default backend specified.",
+				Field: "spec.defaultBackend",
+			}
 		}
 
 		if len(ts.getPorts(ingress)) == 0 {
-			return errors.New("Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
-				" does not have a ports annotation specified.")
+			return &RejectionError{
+				Reason: ReasonMissingAnnotation,
+				Message: "Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
+					" does not have a ports annotation specified.",
+				Field: helper.annotationField(Ports),
+			}
 		}
 
 		if ts.getDefaultDomain(ingress) == "" {
-			return errors.New("Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
-				" does not have a default_domain annotation specified.")
+			return &RejectionError{
+				Reason: ReasonMissingAnnotation,
+				Message: "Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
+					" does not have a default_domain annotation specified.",
+				Field: helper.annotationField(DefaultDomain),
+			}
 		}
 	}
 	return nil
 }
 
 // ValidateDomainClaims checks if the ingress attempts to claim a "Domain" that has already been claimed
-func (ts *ats) ValidateDomainClaims(ingress *v1beta1.Ingress) error {
+func (ts *ats) ValidateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress) error {
 	if ts.ServesIngress(ingress) {
 		domains := ts.GetDomains(ingress)
-		return helper.validateDomainClaims(ingress, domains)
+		return helper.validateDomainClaims(ctx, ingress, domains)
 	}
 	return nil
 }
 
+// GetWarnings returns the ATS domains rewritten by the sanitization, the aliases duplicating the default domain
+// or another alias, along with the common findings
+func (ts *ats) GetWarnings(ingress *networkingv1.Ingress) []string {
+	warnings := []string{}
+	if !ts.ServesIngress(ingress) {
+		return warnings
+	}
+	warnings = append(warnings, helper.getCommonWarnings(ingress)...)
+	defaultDomain := ingress.Annotations[string(DefaultDomain)]
+	if warning := helper.getSanitizeWarning(DefaultDomain, defaultDomain); warning != "" {
+		warnings = append(warnings, warning)
+	}
+
+	rawAliases, exists := ingress.Annotations[string(Aliases)]
+	if !exists {
+		return warnings
+	}
+	for _, alias := range strings.Split(rawAliases, ",") {
+		if warning := helper.getSanitizeWarning(Aliases, alias); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	defaultDomain = ts.getDefaultDomain(ingress)
+	seen := map[string]bool{}
+	for _, alias := range ts.getAliases(ingress) {
+		switch {
+		case alias == defaultDomain:
+			warnings = append(warnings, "Alias "+alias+" duplicates the "+string(DefaultDomain)+" annotation.")
+		case seen[alias]:
+			warnings = append(warnings, "Alias "+alias+" is listed more than once.")
+		}
+		seen[alias] = true
+	}
+	return warnings
+}
+
 // getDefaultDomain returns the sanitized domain specified for the "default_domain" annotation
-func (ts *ats) getDefaultDomain(ingress *v1beta1.Ingress) string {
+func (ts *ats) getDefaultDomain(ingress *networkingv1.Ingress) string {
 	annotationVal, exists := ingress.Annotations[string(DefaultDomain)]
 	if exists {
 		return helper.sanitize(annotationVal)
@@ -103,7 +156,7 @@ func (ts *ats) getDefaultDomain(ingress *v1beta1.Ingress) string {
 }
 
 // getAliases returns the list of sanitized domains specified for the "aliases" annotation
-func (ts *ats) getAliases(ingress *v1beta1.Ingress) []string {
+func (ts *ats) getAliases(ingress *networkingv1.Ingress) []string {
 	aliases := []string{}
 	annotationVal, exists := ingress.Annotations[string(Aliases)]
 	if !exists {
@@ -115,7 +168,7 @@ func (ts *ats) getAliases(ingress *v1beta1.Ingress) []string {
 }
 
 // getPorts returns the list of ports specified for the "ports" annotation
-func (ts *ats) getPorts(ingress *v1beta1.Ingress) []string {
+func (ts *ats) getPorts(ingress *networkingv1.Ingress) []string {
 	ports := []string{}
 	annotationVal, exists := ingress.Annotations[string(Ports)]
 	if !exists {
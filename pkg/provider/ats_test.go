@@ -3,6 +3,7 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -62,7 +63,7 @@ func TestATSServesIngress(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			assert.Equal(t, a.ServesIngress(test.input), test.expected, test.name)
+			assert.Equal(t, a.ServesIngress(mustConvert(test.input)), test.expected, test.name)
 		})
 	}
 }
@@ -114,7 +115,7 @@ func TestATSGetDomains(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			assert.Equal(t, test.expected, a.GetDomains(test.input), test.name)
+			assert.Equal(t, test.expected, a.GetDomains(mustConvert(test.input)), test.name)
 		})
 	}
 }
@@ -286,7 +287,7 @@ func TestATSValidateSemantics(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := a.ValidateSemantics(test.input)
+			err := a.ValidateSemantics(mustConvert(test.input))
 			if test.expected == nil {
 				assert.Nil(t, err, test.name)
 			} else if assert.NotNil(t, err, test.name) {
@@ -296,6 +297,77 @@ func TestATSValidateSemantics(t *testing.T) {
 	}
 }
 
+func TestATSGetWarnings(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		input    *v1beta1.Ingress
+		expected []string
+	}{
+		{
+			"should return empty for a clean ingress",
+			&v1beta1.Ingress{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						string(DefaultDomain): "test.company.com",
+						string(Aliases):       "alias1.company.com, alias2.company.com",
+					},
+				},
+			},
+			[]string{},
+		},
+		{
+			"should return empty for an ingress of another provider",
+			&v1beta1.Ingress{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						string(IngressClass):  Istio,
+						string(DefaultDomain): "Test.company.com",
+					},
+				},
+			},
+			[]string{},
+		},
+		{
+			"should warn about the normalized domains",
+			&v1beta1.Ingress{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						string(DefaultDomain): "Test.company.com",
+						string(Aliases):       "alias1.company.com, Alias2.company.com",
+					},
+				},
+			},
+			[]string{
+				"Domain \"Test.company.com\" of annotation default_domain is normalized to \"test.company.com\".",
+				"Domain \"Alias2.company.com\" of annotation aliases is normalized to \"alias2.company.com\".",
+			},
+		},
+		{
+			"should warn about the duplicated aliases and the deprecated class annotation",
+			&v1beta1.Ingress{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						string(IngressClass):  ATS,
+						string(DefaultDomain): "test.company.com",
+						string(Aliases):       "test.company.com,alias.company.com,alias.company.com",
+					},
+				},
+			},
+			[]string{
+				"Annotation kubernetes.io/ingress.class is deprecated, use spec.ingressClassName instead.",
+				"Alias test.company.com duplicates the default_domain annotation.",
+				"Alias alias.company.com is listed more than once.",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, a.GetWarnings(mustConvert(test.input)), test.name)
+		})
+	}
+}
+
 func TestATSValidateDomainClaims(t *testing.T) {
 
 	refIng := &v1beta1.Ingress{
@@ -469,7 +541,7 @@ func TestATSValidateDomainClaims(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := a.ValidateDomainClaims(test.input)
+			err := a.ValidateDomainClaims(context.TODO(), mustConvert(test.input))
 			if test.expected == nil {
 				assert.Nil(t, err, test.name)
 			} else if assert.NotNil(t, err, test.name) {
@@ -0,0 +1,108 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"errors"
+
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ToIngress converts any of the served Ingress versions (extensions/v1beta1, networking.k8s.io/v1beta1 and
+// networking.k8s.io/v1) into the networking.k8s.io/v1 Ingress used as the internal model by all providers
+func ToIngress(obj interface{}) (*networkingv1.Ingress, error) {
+	switch ingress := obj.(type) {
+	case *networkingv1.Ingress:
+		return ingress, nil
+	case *networkingv1beta1.Ingress:
+		return fromNetworkingV1beta1(ingress), nil
+	case *extv1beta1.Ingress:
+		return fromExtensionsV1beta1(ingress), nil
+	}
+	return nil, errors.New("Resource is not an Ingress kind.")
+}
+
+// fromExtensionsV1beta1 converts an extensions/v1beta1 Ingress into the internal model, through the identical
+// networking.k8s.io/v1beta1 Ingress
+func fromExtensionsV1beta1(in *extv1beta1.Ingress) *networkingv1.Ingress {
+	out := &networkingv1beta1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: networkingv1beta1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+		},
+	}
+	if in.Spec.Backend != nil {
+		backend := networkingv1beta1.IngressBackend(*in.Spec.Backend)
+		out.Spec.Backend = &backend
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1beta1.IngressTLS(tls))
+	}
+	for _, rule := range in.Spec.Rules {
+		outRule := networkingv1beta1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			outRule.HTTP = &networkingv1beta1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				outRule.HTTP.Paths = append(outRule.HTTP.Paths, networkingv1beta1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*networkingv1beta1.PathType)(path.PathType),
+					Backend:  networkingv1beta1.IngressBackend(path.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, outRule)
+	}
+	return fromNetworkingV1beta1(out)
+}
+
+// fromNetworkingV1beta1 converts a networking.k8s.io/v1beta1 Ingress into the internal model
+func fromNetworkingV1beta1(in *networkingv1beta1.Ingress) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+		},
+	}
+	if in.Spec.Backend != nil {
+		out.Spec.DefaultBackend = convertBackend(*in.Spec.Backend)
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1.IngressTLS(tls))
+	}
+	for _, rule := range in.Spec.Rules {
+		outRule := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			outRule.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				outRule.HTTP.Paths = append(outRule.HTTP.Paths, networkingv1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*networkingv1.PathType)(path.PathType),
+					Backend:  *convertBackend(path.Backend),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, outRule)
+	}
+	return out
+}
+
+// convertBackend converts a v1beta1 backend, service name and port pair or resource, into a networking.k8s.io/v1
+// backend
+func convertBackend(in networkingv1beta1.IngressBackend) *networkingv1.IngressBackend {
+	backend := &networkingv1.IngressBackend{Resource: in.Resource}
+	if in.ServiceName == "" {
+		return backend
+	}
+	backend.Service = &networkingv1.IngressServiceBackend{
+		Name: in.ServiceName,
+	}
+	if in.ServicePort.Type == intstr.String {
+		backend.Service.Port.Name = in.ServicePort.StrVal
+	} else {
+		backend.Service.Port.Number = in.ServicePort.IntVal
+	}
+	return backend
+}
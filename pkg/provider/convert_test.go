@@ -0,0 +1,173 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// mustConvert converts a versioned test ingress into the internal model and panics on failure
+func mustConvert(obj interface{}) *networkingv1.Ingress {
+	ingress, err := ToIngress(obj)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ingress
+}
+
+func TestToIngress(t *testing.T) {
+	className := "test-class"
+	prefix := v1beta1.PathTypePrefix
+	networkingPrefix := networkingv1beta1.PathTypePrefix
+	v1Prefix := networkingv1.PathTypePrefix
+
+	expected := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "test-ingress",
+			Namespace: "test-namespace",
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &className,
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: "test-svc",
+					Port: networkingv1.ServiceBackendPort{Number: 80},
+				},
+			},
+			TLS: []networkingv1.IngressTLS{
+				{
+					Hosts:      []string{"test1.company.com"},
+					SecretName: "test-secret",
+				},
+			},
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "test1.company.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/status",
+									PathType: &v1Prefix,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "test2-svc",
+											Port: networkingv1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{
+			"should return a networking.k8s.io/v1 ingress as is",
+			expected,
+		},
+		{
+			"should convert an extensions/v1beta1 ingress",
+			&v1beta1.Ingress{
+				ObjectMeta: expected.ObjectMeta,
+				Spec: v1beta1.IngressSpec{
+					IngressClassName: &className,
+					Backend: &v1beta1.IngressBackend{
+						ServiceName: "test-svc",
+						ServicePort: intstr.FromInt(80),
+					},
+					TLS: []v1beta1.IngressTLS{
+						{
+							Hosts:      []string{"test1.company.com"},
+							SecretName: "test-secret",
+						},
+					},
+					Rules: []v1beta1.IngressRule{
+						{
+							Host: "test1.company.com",
+							IngressRuleValue: v1beta1.IngressRuleValue{
+								HTTP: &v1beta1.HTTPIngressRuleValue{
+									Paths: []v1beta1.HTTPIngressPath{
+										{
+											Path:     "/status",
+											PathType: &prefix,
+											Backend: v1beta1.IngressBackend{
+												ServiceName: "test2-svc",
+												ServicePort: intstr.FromString("http"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"should convert a networking.k8s.io/v1beta1 ingress",
+			&networkingv1beta1.Ingress{
+				ObjectMeta: expected.ObjectMeta,
+				Spec: networkingv1beta1.IngressSpec{
+					IngressClassName: &className,
+					Backend: &networkingv1beta1.IngressBackend{
+						ServiceName: "test-svc",
+						ServicePort: intstr.FromInt(80),
+					},
+					TLS: []networkingv1beta1.IngressTLS{
+						{
+							Hosts:      []string{"test1.company.com"},
+							SecretName: "test-secret",
+						},
+					},
+					Rules: []networkingv1beta1.IngressRule{
+						{
+							Host: "test1.company.com",
+							IngressRuleValue: networkingv1beta1.IngressRuleValue{
+								HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+									Paths: []networkingv1beta1.HTTPIngressPath{
+										{
+											Path:     "/status",
+											PathType: &networkingPrefix,
+											Backend: networkingv1beta1.IngressBackend{
+												ServiceName: "test2-svc",
+												ServicePort: intstr.FromString("http"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := ToIngress(test.input)
+			assert.Nil(t, err, "err should be nil: "+test.name)
+			assert.Equal(t, expected, actual, test.name)
+		})
+	}
+}
+
+func TestToIngressNonIngress(t *testing.T) {
+	_, err := ToIngress(&v1beta1.Deployment{})
+	if assert.NotNil(t, err, "should fail for a non Ingress kind") {
+		assert.Equal(t, "Resource is not an Ingress kind.", err.Error())
+	}
+}
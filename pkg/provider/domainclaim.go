@@ -0,0 +1,176 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DomainClaimIndex is the name of the cache index of the DomainClaim resources by reserved host
+	DomainClaimIndex = "domainclaims"
+)
+
+var (
+	// DomainClaimResource is the resource of the cluster-scoped DomainClaim custom resource definition
+	DomainClaimResource = schema.GroupVersionResource{
+		Group:    "ingressclaim.yahoo.io",
+		Version:  "v1alpha1",
+		Resource: "domainclaims",
+	}
+)
+
+// DomainClaim reserves hosts, or wildcards, to the ingresses of a set of namespaces
+type DomainClaim struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DomainClaimSpec `json:"spec"`
+}
+
+// DomainClaimSpec lists the reserved hosts and the namespaces allowed to claim them. The hosts of a protected
+// DomainClaim cannot be dropped by the ingresses claiming them.
+type DomainClaimSpec struct {
+	Hosts      []string `json:"hosts"`
+	Namespaces []string `json:"namespaces"`
+	Protected  bool     `json:"protected,omitempty"`
+}
+
+// ToDomainClaim converts a DomainClaim resource, as listed by the dynamic client, into a DomainClaim
+func ToDomainClaim(obj interface{}) (*DomainClaim, error) {
+	switch claim := obj.(type) {
+	case *DomainClaim:
+		return claim, nil
+	case *unstructured.Unstructured:
+		domainClaim := &DomainClaim{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(claim.Object, domainClaim); err != nil {
+			return nil, err
+		}
+		return domainClaim, nil
+	}
+	return nil, errors.New("Resource is not a DomainClaim kind.")
+}
+
+// DomainClaimsIndexFunc returns the list of hosts reserved by the given DomainClaim
+func DomainClaimsIndexFunc(obj interface{}) ([]string, error) {
+	claim, err := ToDomainClaim(obj)
+	if err != nil {
+		return nil, err
+	}
+	return helper.appendNonEmpty([]string{}, claim.Spec.Hosts...), nil
+}
+
+// SetDomainClaimIndexer allows to set the cache indexer of the DomainClaim resources, the DomainClaims are
+// not consulted as long as it is not set
+func (h *Helper) SetDomainClaimIndexer(indexer cache.Indexer) {
+	h.reservations = indexer
+}
+
+// getReservedHosts returns the reserved hosts overlapping the domain, that is the domain itself, the wildcard
+// covering it, or for a wildcard domain every domain beneath it
+func (h *Helper) getReservedHosts(domain string) []string {
+	reserved := []string{domain}
+	if h.isWildcard(domain) {
+		beneath := []string{}
+		for _, indexed := range h.reservations.ListIndexFuncValues(DomainClaimIndex) {
+			if h.wildcardOf(indexed) == domain {
+				beneath = append(beneath, indexed)
+			}
+		}
+		sort.Strings(beneath)
+		reserved = append(reserved, beneath...)
+	} else if wildcard := h.wildcardOf(domain); wildcard != "" {
+		reserved = append(reserved, wildcard)
+	}
+	return reserved
+}
+
+// lookupReservations returns the DomainClaims reserving the host, by name
+func (h *Helper) lookupReservations(host string) ([](*DomainClaim), error) {
+	matches, err := h.reservations.ByIndex(DomainClaimIndex, host)
+	if err != nil {
+		return nil, err
+	}
+	claims := [](*DomainClaim){}
+	for _, match := range matches {
+		if claim, err := ToDomainClaim(match); err == nil {
+			claims = append(claims, claim)
+		}
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		return claims[i].Name < claims[j].Name
+	})
+	return claims, nil
+}
+
+// lookupDomainClaims returns the DomainClaims reserving the domain, that is reserving the domain itself, the
+// wildcard covering it, or for a wildcard domain any domain beneath it
+func (h *Helper) lookupDomainClaims(domain string) ([](*DomainClaim), error) {
+	claims := [](*DomainClaim){}
+	for _, host := range h.getReservedHosts(domain) {
+		matches, err := h.lookupReservations(host)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, matches...)
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		return claims[i].Name < claims[j].Name
+	})
+	return claims, nil
+}
+
+// validateReservation checks that the ingress lives in a namespace allowed by the DomainClaims reserving the
+// domain, if any. The DomainClaims reserving a host take precedence over those reserving the wildcard covering
+// it, while a wildcard domain must be allowed by the DomainClaims reserving every domain beneath it.
+func (h *Helper) validateReservation(ingress *networkingv1.Ingress, domain string) error {
+	if h.reservations == nil {
+		return nil
+	}
+	for _, host := range h.getReservedHosts(domain) {
+		claims, err := h.lookupReservations(host)
+		if err != nil {
+			return err
+		}
+		if len(claims) == 0 {
+			continue
+		}
+		if h.allowsNamespace(claims, ingress.Namespace) {
+			if !h.isWildcard(domain) {
+				return nil
+			}
+			continue
+		}
+		return &RejectionError{
+			Reason: ReasonDomainReserved,
+			Message: fmt.Sprintf("Domain %s is reserved by DomainClaim %s for namespaces: %s.", domain,
+				claims[0].Name, strings.Join(claims[0].Spec.Namespaces, ", ")),
+			Field: h.domainField(ingress, domain),
+			Host:  domain,
+			Owner: claims[0].Name,
+		}
+	}
+	return nil
+}
+
+// allowsNamespace checks if one of the DomainClaims allows the namespace
+func (h *Helper) allowsNamespace(claims [](*DomainClaim), namespace string) bool {
+	for _, claim := range claims {
+		for _, allowed := range claim.Spec.Namespaces {
+			if allowed == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestToDomainClaim(t *testing.T) {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "ingressclaim.yahoo.io/v1alpha1",
+			"kind":       "DomainClaim",
+			"metadata": map[string]interface{}{
+				"name": "test-claim",
+			},
+			"spec": map[string]interface{}{
+				"hosts":      []interface{}{"test1.company.com", "*.test2.company.com"},
+				"namespaces": []interface{}{"test-namespace"},
+			},
+		},
+	}
+
+	claim, err := ToDomainClaim(obj)
+	if assert.Nil(t, err, "should convert an unstructured DomainClaim") {
+		assert.Equal(t, "test-claim", claim.Name)
+		assert.Equal(t, []string{"test1.company.com", "*.test2.company.com"}, claim.Spec.Hosts)
+		assert.Equal(t, []string{"test-namespace"}, claim.Spec.Namespaces)
+	}
+
+	_, err = ToDomainClaim(&networkingv1.Ingress{})
+	assert.NotNil(t, err, "should fail for a non DomainClaim kind")
+}
+
+func TestDomainClaimsIndexFunc(t *testing.T) {
+	domains, err := DomainClaimsIndexFunc(&DomainClaim{
+		Spec: DomainClaimSpec{
+			Hosts: []string{"Test1.company.com", " ", "*.test2.company.com"},
+		},
+	})
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, []string{"test1.company.com", "*.test2.company.com"}, domains)
+
+	_, err = DomainClaimsIndexFunc(&networkingv1.Ingress{})
+	assert.NotNil(t, err, "should fail for a non DomainClaim kind")
+}
+
+func TestValidateReservedDomainClaims(t *testing.T) {
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	reservations := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{DomainClaimIndex: DomainClaimsIndexFunc})
+	reservations.Add(&DomainClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "test-claim-payments",
+		},
+		Spec: DomainClaimSpec{
+			Hosts:      []string{"payments.company.com", "*.payments.company.com"},
+			Namespaces: []string{"test-ns-payments", "test-ns-checkout"},
+		},
+	})
+	reservations.Add(&DomainClaim{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "test-claim-search",
+		},
+		Spec: DomainClaimSpec{
+			Hosts:      []string{"search.company.com"},
+			Namespaces: []string{"test-ns-search"},
+		},
+	})
+	for _, reserved := range []struct{ name, host, namespace string }{
+		{"test-claim-beneath-a", "a.beneath.company.com", "test-ns-b"},
+		{"test-claim-beneath-b", "b.beneath.company.com", "test-ns-a"},
+		{"test-claim-exact", "a.exact.company.com", "test-ns-b"},
+		{"test-claim-exact-cover", "*.exact.company.com", "test-ns-c"},
+	} {
+		reservations.Add(&DomainClaim{
+			ObjectMeta: v1.ObjectMeta{
+				Name: reserved.name,
+			},
+			Spec: DomainClaimSpec{
+				Hosts:      []string{reserved.host},
+				Namespaces: []string{reserved.namespace},
+			},
+		})
+	}
+	helper.SetDomainClaimIndexer(reservations)
+
+	tests := []struct {
+		name     string
+		input    *networkingv1.Ingress
+		expected error
+	}{
+		{
+			"should pass for a reserved domain in an allowed namespace",
+			newIstioIngress("test-ns-checkout", "test-ingress", "payments.company.com"),
+			nil,
+		},
+		{
+			"should pass for a domain beneath a reserved wildcard in an allowed namespace",
+			newIstioIngress("test-ns-payments", "test-ingress", "api.payments.company.com"),
+			nil,
+		},
+		{
+			"should pass for a domain that is not reserved",
+			newIstioIngress("test-ns-other", "test-ingress", "other.company.com"),
+			nil,
+		},
+		{
+			"should fail for a reserved domain in another namespace",
+			newIstioIngress("test-ns-other", "test-ingress", "payments.company.com"),
+			errors.New("Domain payments.company.com is reserved by DomainClaim test-claim-payments for " +
+				"namespaces: test-ns-payments, test-ns-checkout."),
+		},
+		{
+			"should fail for a domain beneath a reserved wildcard in another namespace",
+			newIstioIngress("test-ns-other", "test-ingress", "api.payments.company.com"),
+			errors.New("Domain api.payments.company.com is reserved by DomainClaim test-claim-payments for " +
+				"namespaces: test-ns-payments, test-ns-checkout."),
+		},
+		{
+			"should fail for a wildcard covering a reserved domain in another namespace",
+			newIstioIngress("test-ns-other", "test-ingress", "*.company.com"),
+			errors.New("Domain *.company.com is reserved by DomainClaim test-claim-payments for " +
+				"namespaces: test-ns-payments, test-ns-checkout."),
+		},
+		{
+			"should fail for a wildcard covering a domain reserved for another namespace among allowed ones",
+			newIstioIngress("test-ns-a", "test-ingress", "*.beneath.company.com"),
+			errors.New("Domain *.beneath.company.com is reserved by DomainClaim test-claim-beneath-a for " +
+				"namespaces: test-ns-b."),
+		},
+		{
+			"should fail for a reserved domain beneath a wildcard reserved for the namespace",
+			newIstioIngress("test-ns-c", "test-ingress", "a.exact.company.com"),
+			errors.New("Domain a.exact.company.com is reserved by DomainClaim test-claim-exact for " +
+				"namespaces: test-ns-b."),
+		},
+		{
+			"should pass for a reserved domain in an allowed namespace beneath a wildcard reserved for another",
+			newIstioIngress("test-ns-b", "test-ingress", "a.exact.company.com"),
+			nil,
+		},
+		{
+			"should pass for a domain beneath a wildcard reserved for the namespace",
+			newIstioIngress("test-ns-c", "test-ingress", "b.exact.company.com"),
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := helper.validateDomainClaims(context.TODO(), test.input, helper.GetProvider(test.input).GetDomains(test.input))
+			if test.expected == nil {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected.Error(), err.Error(), test.name)
+			}
+		})
+	}
+	helper.SetDomainClaimIndexer(nil)
+}
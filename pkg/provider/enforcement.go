@@ -0,0 +1,51 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"fmt"
+)
+
+// EnforcementMode defines what happens to the ingresses failing the validation or the domain claim checks
+type EnforcementMode string
+
+const (
+	// EnforcementEnforce denies the ingresses failing the checks
+	EnforcementEnforce EnforcementMode = "enforce"
+
+	// EnforcementAudit admits the ingresses failing the checks, the would-be denials are logged, counted and
+	// returned as admission warnings
+	EnforcementAudit EnforcementMode = "audit"
+)
+
+// ParseEnforcementMode returns the enforcement mode with the given name
+func ParseEnforcementMode(name string) (EnforcementMode, error) {
+	switch mode := EnforcementMode(name); mode {
+	case EnforcementEnforce, EnforcementAudit:
+		return mode, nil
+	}
+	return "", fmt.Errorf("Unknown enforcement mode: %s", name)
+}
+
+// SetEnforcementModes sets the default enforcement mode along with the modes overriding it for the providers
+// and for the namespaces by name, the namespace modes take precedence over the provider modes
+func (h *Helper) SetEnforcementModes(mode EnforcementMode, providers map[string]EnforcementMode,
+	namespaces map[string]EnforcementMode) {
+	h.enforcement = mode
+	h.providerEnforcement = providers
+	h.namespaceEnforcement = namespaces
+}
+
+// GetEnforcementMode returns the enforcement mode of the ingresses of the named provider in the namespace
+func (h *Helper) GetEnforcementMode(provider string, namespace string) EnforcementMode {
+	if mode, exists := h.namespaceEnforcement[namespace]; exists {
+		return mode
+	}
+	if mode, exists := h.providerEnforcement[provider]; exists {
+		return mode
+	}
+	if h.enforcement == "" {
+		return EnforcementEnforce
+	}
+	return h.enforcement
+}
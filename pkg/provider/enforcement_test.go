@@ -0,0 +1,37 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnforcementMode(t *testing.T) {
+	mode, err := ParseEnforcementMode("audit")
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, EnforcementAudit, mode)
+
+	_, err = ParseEnforcementMode("warn")
+	assert.NotNil(t, err, "should fail for an unknown enforcement mode")
+}
+
+func TestGetEnforcementMode(t *testing.T) {
+	assert.Equal(t, EnforcementEnforce, helper.GetEnforcementMode(ATS, "test-namespace"),
+		"should enforce by default")
+
+	helper.SetEnforcementModes(EnforcementEnforce,
+		map[string]EnforcementMode{Istio: EnforcementAudit},
+		map[string]EnforcementMode{"test-audit": EnforcementAudit, "test-enforce": EnforcementEnforce})
+	defer helper.SetEnforcementModes(EnforcementEnforce, nil, nil)
+
+	assert.Equal(t, EnforcementEnforce, helper.GetEnforcementMode(ATS, "test-namespace"),
+		"should apply the default mode")
+	assert.Equal(t, EnforcementAudit, helper.GetEnforcementMode(Istio, "test-namespace"),
+		"should apply the provider mode")
+	assert.Equal(t, EnforcementAudit, helper.GetEnforcementMode(ATS, "test-audit"),
+		"should apply the namespace mode")
+	assert.Equal(t, EnforcementEnforce, helper.GetEnforcementMode(Istio, "test-enforce"),
+		"should apply the namespace mode over the provider mode")
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"errors"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// RejectionReason is the machine-readable category of a rejected ingress
+type RejectionReason string
+
+const (
+	// ReasonDomainConflict rejects a claim on a domain, or path, owned by another ingress
+	ReasonDomainConflict RejectionReason = "DomainConflict"
+
+	// ReasonDomainReserved rejects a claim on a domain reserved by a DomainClaim for other namespaces
+	ReasonDomainReserved RejectionReason = "DomainReserved"
+
+	// ReasonClaimPending rejects a claim on a domain being claimed concurrently by another owner
+	ReasonClaimPending RejectionReason = "ClaimPending"
+
+	// ReasonMissingAnnotation rejects an ingress without an annotation required by its provider
+	ReasonMissingAnnotation RejectionReason = "MissingAnnotation"
+
+	// ReasonInvalidBackend rejects an ingress with a backend not supported by its provider
+	ReasonInvalidBackend RejectionReason = "InvalidBackend"
+
+	// ReasonInvalidRule rejects an ingress with a rule not supported by its provider
+	ReasonInvalidRule RejectionReason = "InvalidRule"
+
+	// ReasonProtectedHost rejects an update dropping, or a deletion removing, a protected domain
+	ReasonProtectedHost RejectionReason = "ProtectedHost"
+)
+
+// RejectionError is a typed rejection of an ingress naming the offending field and, for the claim rejections,
+// the claimed host along with its owner
+type RejectionError struct {
+	Reason  RejectionReason
+	Message string
+
+	// Field is the path of the offending field, such as spec.rules[0].host
+	Field string
+	// Host is the claimed host, and Path the claimed path with the path claim granularity
+	Host string
+	Path string
+	// Owner is the namespace/name of the owning ingress, the name of the reserving DomainClaim or the identity
+	// of the concurrent claim owner
+	Owner string
+}
+
+// Error returns the human readable message of the rejection
+func (e *RejectionError) Error() string {
+	return e.Message
+}
+
+// IsConflict checks if the rejection is due to a claim of another owner, as opposed to an invalid ingress
+func (e *RejectionError) IsConflict() bool {
+	switch e.Reason {
+	case ReasonDomainConflict, ReasonDomainReserved, ReasonClaimPending:
+		return true
+	}
+	return false
+}
+
+// RejectionErrors aggregates the rejections of an ingress, such as the conflicts on all its claimed domains
+type RejectionErrors []*RejectionError
+
+// Error returns the human readable messages of the rejections
+func (e RejectionErrors) Error() string {
+	messages := []string{}
+	for _, rejection := range e {
+		messages = append(messages, rejection.Message)
+	}
+	return strings.Join(messages, " ")
+}
+
+// newRejectionErrors returns the error of the rejections dropping the duplicated ones, the single rejection
+// itself or the aggregated rejections
+func newRejectionErrors(rejections []*RejectionError) error {
+	unique := RejectionErrors{}
+	seen := map[string]bool{}
+	for _, rejection := range rejections {
+		if !seen[rejection.Message] {
+			seen[rejection.Message] = true
+			unique = append(unique, rejection)
+		}
+	}
+	if len(unique) == 1 {
+		return unique[0]
+	}
+	return unique
+}
+
+// Rejections returns the typed rejections of the error, a single or aggregated RejectionError, nil for any
+// other error
+func Rejections(err error) []*RejectionError {
+	var rejections RejectionErrors
+	if errors.As(err, &rejections) {
+		return rejections
+	}
+	var rejection *RejectionError
+	if errors.As(err, &rejection) {
+		return []*RejectionError{rejection}
+	}
+	return nil
+}
+
+// annotationField returns the path of the annotation field
+func (h *Helper) annotationField(annotation Annotation) string {
+	return field.NewPath("metadata", "annotations").Key(string(annotation)).String()
+}
+
+// ruleHostField returns the path of the host of the rule with the given index
+func (h *Helper) ruleHostField(index int) string {
+	return field.NewPath("spec", "rules").Index(index).Child("host").String()
+}
+
+// domainField returns the path of the field of the ingress declaring the domain, the first rule host matching
+// it or the ATS domain annotations, "" if the domain is not found
+func (h *Helper) domainField(ingress *networkingv1.Ingress, domain string) string {
+	for i, rule := range ingress.Spec.Rules {
+		if h.sanitize(rule.Host) == domain {
+			return h.ruleHostField(i)
+		}
+	}
+	if h.sanitize(ingress.Annotations[string(DefaultDomain)]) == domain {
+		return h.annotationField(DefaultDomain)
+	}
+	for _, alias := range strings.Split(h.sanitize(ingress.Annotations[string(Aliases)]), ",") {
+		if alias != "" && alias == domain {
+			return h.annotationField(Aliases)
+		}
+	}
+	return ""
+}
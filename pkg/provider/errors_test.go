@@ -0,0 +1,74 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRejectionErrorIsConflict(t *testing.T) {
+	tests := []struct {
+		reason   RejectionReason
+		expected bool
+	}{
+		{ReasonDomainConflict, true},
+		{ReasonDomainReserved, true},
+		{ReasonClaimPending, true},
+		{ReasonMissingAnnotation, false},
+		{ReasonInvalidBackend, false},
+		{ReasonInvalidRule, false},
+	}
+	for _, test := range tests {
+		err := &RejectionError{Reason: test.reason, Message: "rejected"}
+		assert.Equal(t, test.expected, err.IsConflict(), string(test.reason))
+		assert.Equal(t, "rejected", err.Error(), "should return the message")
+	}
+}
+
+func TestDomainField(t *testing.T) {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				string(DefaultDomain): "Default.company.com",
+				string(Aliases):       "alias1.company.com, alias2.company.com",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{Host: "rule1.company.com"},
+				{Host: "rule2.company.com"},
+			},
+		},
+	}
+	tests := []struct {
+		domain   string
+		expected string
+	}{
+		{"rule2.company.com", "spec.rules[1].host"},
+		{"default.company.com", "metadata.annotations[default_domain]"},
+		{"alias2.company.com", "metadata.annotations[aliases]"},
+		{"unknown.company.com", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, helper.domainField(ingress, test.domain), test.domain)
+	}
+}
+
+func TestRejections(t *testing.T) {
+	first := &RejectionError{Reason: ReasonDomainConflict, Message: "first."}
+	second := &RejectionError{Reason: ReasonDomainReserved, Message: "second."}
+
+	assert.Equal(t, first, newRejectionErrors([]*RejectionError{first, first}),
+		"should return a single rejection itself")
+	err := newRejectionErrors([]*RejectionError{first, second, first})
+	assert.Equal(t, "first. second.", err.Error(), "should join the messages of the unique rejections")
+
+	assert.Equal(t, []*RejectionError{first, second}, Rejections(err), "should return the aggregated rejections")
+	assert.Equal(t, []*RejectionError{first}, Rejections(first), "should return a single rejection")
+	assert.Nil(t, Rejections(errors.New("lookup failed")), "should return no rejections for other errors")
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"strings"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ClaimGroupIndexPrefix prefixes the names of the cache indexes of the claim groups
+	ClaimGroupIndexPrefix = "group:"
+
+	// AllProviders is the provider name in the claim groups standing for the providers without a group of their own
+	AllProviders = "*"
+)
+
+// SetClaimGroups sets the claim groups of the providers by provider name. The claims of the providers of a
+// group are checked against each other through the unified index of the group, so that an ingress cannot claim
+// a domain owned by an ingress of another provider of its group. The providers without a group check their
+// claims against their own index.
+func (h *Helper) SetClaimGroups(groups map[string]string) {
+	h.groups = groups
+}
+
+// GetIndexers returns the cache indexers of the enabled providers and of their claim groups, to be set on the
+// informer. The ingresses are indexed on the domains every provider would claim whatever their class, so that the
+// index does not depend on the IngressClass resources, and the lookups check the matches against the provider
+// their class currently resolves to.
+func (h *Helper) GetIndexers() cache.Indexers {
+	indexers := cache.Indexers{}
+	for _, name := range h.enabled {
+		name := name
+		indexers[name] = func(obj interface{}) ([]string, error) {
+			return h.indexAs(name, obj)
+		}
+		if group := h.getClaimGroup(name); group != "" {
+			indexers[ClaimGroupIndexPrefix+group] = h.groupIndexFunc(group, h.indexAs)
+		}
+	}
+	return indexers
+}
+
+// indexAs returns the domains indexed by the named provider for the ingress, as if the ingress resolved to it
+func (h *Helper) indexAs(name string, obj interface{}) ([]string, error) {
+	ingress, err := ToIngress(obj)
+	if err != nil {
+		return nil, err
+	}
+	// a copy of the ingress is resolved to the provider for the time of the index func
+	resolved := *ingress
+	h.indexing.Store(&resolved, name)
+	defer h.indexing.Delete(&resolved)
+	return h.providers[name].DomainsIndexFunc(&resolved)
+}
+
+// getClaimGroup returns the claim group of the named provider, "" if it has none
+func (h *Helper) getClaimGroup(name string) string {
+	if group, exists := h.groups[name]; exists {
+		return group
+	}
+	return h.groups[AllProviders]
+}
+
+// getClaimIndex returns the name of the cache index the claims of the named provider are checked against,
+// the index of its claim group or its own index
+func (h *Helper) getClaimIndex(name string) string {
+	if group := h.getClaimGroup(name); group != "" {
+		return ClaimGroupIndexPrefix + group
+	}
+	return name
+}
+
+// getIndexFunc returns the index func of the cache index with the name 'index', nil if it is not known
+func (h *Helper) getIndexFunc(index string) cache.IndexFunc {
+	if strings.HasPrefix(index, ClaimGroupIndexPrefix) {
+		return h.claimGroupIndexFunc(strings.TrimPrefix(index, ClaimGroupIndexPrefix))
+	}
+	if provider, exists := h.providers[index]; exists {
+		return provider.DomainsIndexFunc
+	}
+	return nil
+}
+
+// claimGroupIndexFunc returns the index func of the claim group, indexing the domains claimed by the ingresses
+// of all the enabled providers of the group
+func (h *Helper) claimGroupIndexFunc(group string) cache.IndexFunc {
+	return h.groupIndexFunc(group, func(name string, obj interface{}) ([]string, error) {
+		return h.providers[name].DomainsIndexFunc(obj)
+	})
+}
+
+// groupIndexFunc returns the index func of the claim group, indexing the domains indexed for every enabled
+// provider of the group by the given provider index func
+func (h *Helper) groupIndexFunc(group string, indexFunc func(name string, obj interface{}) ([]string,
+	error)) cache.IndexFunc {
+	return func(obj interface{}) ([]string, error) {
+		domains := []string{}
+		seen := map[string]bool{}
+		for _, name := range h.enabled {
+			if h.getClaimGroup(name) != group {
+				continue
+			}
+			providerDomains, err := indexFunc(name, obj)
+			if err != nil {
+				return nil, err
+			}
+			for _, domain := range providerDomains {
+				if !seen[domain] {
+					seen[domain] = true
+					domains = append(domains, domain)
+				}
+			}
+		}
+		return domains, nil
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// setupClaimGroups sets the claim groups and the cache indexer with the index of every provider and group
+func setupClaimGroups(groups map[string]string, ingresses ...*networkingv1.Ingress) {
+	helper.SetClaimGroups(groups)
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, helper.GetIndexers())
+	for _, ingress := range ingresses {
+		indexer.Add(ingress)
+	}
+	helper.SetIndexer(indexer)
+}
+
+func TestGetClaimIndex(t *testing.T) {
+	helper.SetClaimGroups(map[string]string{ATS: "public"})
+	assert.Equal(t, "group:public", helper.getClaimIndex(ATS), "should check the claims against the group index")
+	assert.Equal(t, Istio, helper.getClaimIndex(Istio), "should check the claims against the provider index")
+
+	helper.SetClaimGroups(map[string]string{AllProviders: "public", Istio: "mesh"})
+	assert.Equal(t, "group:public", helper.getClaimIndex(ATS), "should apply the group of all the providers")
+	assert.Equal(t, "group:mesh", helper.getClaimIndex(Istio), "should apply the group of the provider")
+
+	helper.SetClaimGroups(nil)
+	assert.Equal(t, ATS, helper.getClaimIndex(ATS), "should check the claims against the provider index")
+}
+
+func TestCrossProviderDomainClaims(t *testing.T) {
+	atsIngress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "ats-ingress",
+			Namespace: "ats-namespace",
+			Annotations: map[string]string{
+				string(IngressClass):  ATS,
+				string(DefaultDomain): "app.company.com",
+				string(Ports):         "80",
+			},
+		},
+	}
+	istioIngress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "istio-ingress",
+			Namespace: "istio-namespace",
+			Annotations: map[string]string{
+				string(IngressClass): Istio,
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "app.company.com",
+				},
+			},
+		},
+	}
+	validate := func(ingress *networkingv1.Ingress) error {
+		return helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
+	}
+	defer setupClaimGroups(nil)
+
+	tests := []struct {
+		name     string
+		groups   map[string]string
+		expected bool
+	}{
+		{
+			"should admit a domain owned by another provider without claim groups",
+			nil,
+			false,
+		},
+		{
+			"should reject a domain owned by another provider of the same group",
+			map[string]string{ATS: "public", Istio: "public"},
+			true,
+		},
+		{
+			"should reject a domain owned by another provider when all providers share a group",
+			map[string]string{AllProviders: "public"},
+			true,
+		},
+		{
+			"should admit a domain owned by another provider of another group",
+			map[string]string{ATS: "public", Istio: "mesh"},
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupClaimGroups(test.groups, atsIngress)
+			err := validate(istioIngress)
+			if !test.expected {
+				assert.Nil(t, err, test.name)
+				return
+			}
+			if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, "Domain app.company.com already exists. Ingress ats-ingress in namespace "+
+					"ats-namespace owns this domain.", err.Error())
+			}
+		})
+	}
+}
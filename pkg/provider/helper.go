@@ -3,31 +3,58 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
-	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
 var (
-	helper *Helper
+	helper = newHelper()
 )
 
 // Helper class that provides common validation funcs and a handle to
 // ingress claim provider implementations
 type Helper struct {
-	providers map[string]Provider
-	indexer   cache.Indexer
+	providers    map[string]Provider
+	registered   []string
+	enabled      []string
+	defaultName  string
+	indexer      cache.Indexer
+	classes      cache.Store
+	classNames   map[string]string
+	controllers  map[string]string
+	groups       map[string]string
+	protected    []string
+	policies     map[string]ClaimPolicy
+	namespaces   cache.Store
+	reservations cache.Indexer
+	leases       *ClaimLeases
+	pending      *pendingClaims
+
+	// indexing holds the provider name the ingresses being indexed resolve to, see GetIndexers
+	indexing sync.Map
+
+	enforcement          EnforcementMode
+	updateValidation     UpdateValidation
+	providerEnforcement  map[string]EnforcementMode
+	namespaceEnforcement map[string]EnforcementMode
+
+	// claimLock serializes the domain claim checks with the reservation of the admitted claims
+	claimLock sync.Mutex
 }
 
-// init sets-up the provider instances
-func init() {
-	helper = &Helper{
-		providers: map[string]Provider{
-			ATS:   NewATSProvider(),
-			Istio: NewIstioProvider(),
-		},
+// newHelper returns the helper instance without any provider, the providers are added through Register
+func newHelper() *Helper {
+	return &Helper{
+		providers:   map[string]Provider{},
+		controllers: map[string]string{},
+		policies:    map[string]ClaimPolicy{},
+		pending:     newPendingClaims(0),
 	}
 }
 
@@ -36,23 +63,43 @@ func GetHelper() *Helper {
 	return helper
 }
 
-// GetDefaultProvider returns the default ingress claim provider instance
+// GetDefaultProvider returns the default ingress claim provider instance serving the ingresses without a class,
+// which is the enabled provider of the cluster default IngressClass, or the configured default provider. The
+// "none" provider is returned when the default provider is not enabled.
 func (h *Helper) GetDefaultProvider() Provider {
-	return h.providers[ATS]
+	if name := h.getDefaultProviderName(); h.isEnabled(name) {
+		return h.providers[name]
+	}
+	return noneProvider
 }
 
-// GetProvider returns the provider instance corresponding to the given ingress resource
-func (h *Helper) GetProvider(ingress *v1beta1.Ingress) Provider {
-	for _, provider := range h.providers {
-		if provider.ServesIngress(ingress) {
+// GetProvider returns the provider instance corresponding to the given ingress resource, which is the first
+// enabled provider serving it in the order of precedence. The "none" provider is returned for the ingresses
+// served by no enabled provider, their checks are skipped.
+func (h *Helper) GetProvider(ingress *networkingv1.Ingress) Provider {
+	for _, name := range h.enabled {
+		if provider := h.providers[name]; provider.ServesIngress(ingress) {
 			return provider
 		}
 	}
-	return h.GetDefaultProvider()
+	return noneProvider
+}
+
+// isEnabled checks if the provider with the given name is enabled
+func (h *Helper) isEnabled(name string) bool {
+	for _, enabled := range h.enabled {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
 }
 
 // GetProviderByName returns a handle to the provider instance by the provider name
 func (h *Helper) GetProviderByName(name string) Provider {
+	if name == None {
+		return noneProvider
+	}
 	return h.providers[name]
 }
 
@@ -78,36 +125,270 @@ func (h *Helper) appendNonEmpty(slice []string, items ...string) []string {
 	return slice
 }
 
+// isWildcard checks if the domain is a wildcard domain such as *.company.com
+func (h *Helper) isWildcard(domain string) bool {
+	return strings.HasPrefix(domain, "*.")
+}
+
+// wildcardOf returns the wildcard domain covering the given domain, a wildcard covers exactly one
+// DNS label so the wildcard of app.company.com is *.company.com. Returns "" for single label domains.
+func (h *Helper) wildcardOf(domain string) string {
+	i := strings.Index(domain, ".")
+	if i < 0 || h.isWildcard(domain) {
+		return ""
+	}
+	return "*" + domain[i:]
+}
+
 // lookupIngressesByDomain provides a lookup on the cache index with the name 'index'
-// on the 'domain', this assumes SetIndexer has been called previously
-func (h *Helper) lookupIngressesByDomain(index string, domain string) (ingresses [](*v1beta1.Ingress), err error) {
+// on the 'domain', this assumes SetIndexer has been called previously. The matches are
+// converted to the internal Ingress model regardless of the version the informer watches.
+// The pending ingresses claiming the domain are included in addition to their cached version, so that the domains
+// dropped by an admitted update stay claimed until the informer observes it, in case the update is not persisted.
+// The cached matches are checked against the current index func, as an ingress indexed before the resolution
+// of its class changed may still be indexed on the domains of its former provider.
+// The matches are sorted by namespace/name so that the first conflicting ingress is reported consistently.
+func (h *Helper) lookupIngressesByDomain(index string, domain string) (ingresses [](*networkingv1.Ingress), err error) {
 	matches, err := h.indexer.ByIndex(index, domain)
 	if err != nil {
 		return ingresses, err
 	}
+	cached := map[string]bool{}
 	for _, match := range matches {
-		if ingress, ok := match.(*v1beta1.Ingress); ok {
-			ingresses = append(ingresses, ingress)
+		ingress, err := ToIngress(match)
+		if err != nil || !h.indexesDomain(index, ingress, domain) {
+			continue
+		}
+		cached[ingress.Namespace+"/"+ingress.Name] = true
+		ingresses = append(ingresses, ingress)
+	}
+	sort.Slice(ingresses, func(i, j int) bool {
+		return ingresses[i].Namespace+"/"+ingresses[i].Name < ingresses[j].Namespace+"/"+ingresses[j].Name
+	})
+	pending := h.pending.list()
+	keys := []string{}
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !cached[key] && h.indexesDomain(index, pending[key], domain) {
+			ingresses = append(ingresses, pending[key])
 		}
 	}
 	return ingresses, nil
 }
 
+// claimConflict is the claim of an ingress of another owner conflicting with the claim of an ingress, narrowed
+// down to the overlapping path with the path claim granularity
+type claimConflict struct {
+	owner *networkingv1.Ingress
+	path  string
+}
+
+// lookupConflictingIngresses returns all the ingresses of other owners whose claim on ownerDomain, looked up on
+// the cache index with the name 'index', conflicts with the claim of the ingress on domain. With the path claim
+// granularity only the ingresses routing an overlapping path conflict, and the overlapping path of the given
+// ingress is returned along. Ingresses handing the domain over to each other do not conflict.
+func (h *Helper) lookupConflictingIngresses(index string, policy ClaimPolicy, ingress *networkingv1.Ingress,
+	domain string, ownerDomain string) ([]claimConflict, error) {
+	ingressMatches, err := h.lookupIngressesByDomain(index, ownerDomain)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []claimConflict{}
+	owner := h.getClaimOwner(policy, ingress)
+	for _, ingressMatch := range ingressMatches {
+		if ingressMatch.Namespace == ingress.Namespace && ingressMatch.Name == ingress.Name {
+			continue
+		}
+		if h.getClaimOwner(policy, ingressMatch) == owner ||
+			h.isHandedOver(index, ingress, domain, ingressMatch, ownerDomain) {
+			continue
+		}
+		if policy.Granularity != GranularityPath {
+			conflicts = append(conflicts, claimConflict{owner: ingressMatch})
+			continue
+		}
+		if path, overlaps := h.pathsOverlap(h.getHostPaths(ingress, domain),
+			h.getHostPaths(ingressMatch, ownerDomain)); overlaps {
+			conflicts = append(conflicts, claimConflict{owner: ingressMatch, path: path})
+		}
+	}
+	return conflicts, nil
+}
+
+// conflictErrors returns the rejection errors for the claim of the ingress on domain conflicting with the claims
+// on ownerDomain
+func (h *Helper) conflictErrors(ingress *networkingv1.Ingress, domain string, ownerDomain string,
+	conflicts []claimConflict) []*RejectionError {
+	rejections := []*RejectionError{}
+	for _, conflict := range conflicts {
+		rejections = append(rejections, h.conflictError(ingress, domain, ownerDomain, conflict.path, conflict.owner))
+	}
+	return rejections
+}
+
+// conflictError returns the rejection error for a claim of the ingress on domain conflicting with the claim of
+// the owner ingress on ownerDomain, optionally narrowed down to the conflicting path
+func (h *Helper) conflictError(ingress *networkingv1.Ingress, domain string, ownerDomain string, path string,
+	owner *networkingv1.Ingress) *RejectionError {
+	claim, subject := "Domain "+domain+" already exists", "domain"
+	if domain != ownerDomain {
+		if h.isWildcard(domain) {
+			claim = "Wildcard domain " + domain + " overlaps domain " + ownerDomain
+		} else {
+			claim = "Domain " + domain + " overlaps wildcard domain " + ownerDomain
+		}
+	}
+	if path != "" {
+		claim, subject = claim+" on path "+path, "path"
+	}
+	return &RejectionError{
+		Reason: ReasonDomainConflict,
+		Message: fmt.Sprintf("%s. Ingress %s in namespace %s owns this %s.", claim, owner.Name, owner.Namespace,
+			subject),
+		Field: h.domainField(ingress, domain),
+		Host:  domain,
+		Path:  path,
+		Owner: owner.Namespace + "/" + owner.Name,
+	}
+}
+
+// validateWildcardClaim checks the domain against the claims overlapping it through a wildcard according to
+// the wildcard policy: the domains beneath a claimed wildcard, or the wildcard covering a claimed domain.
+// The rejection errors of all the overlapping claims are returned.
+func (h *Helper) validateWildcardClaim(index string, policy ClaimPolicy, ingress *networkingv1.Ingress,
+	domain string) ([]*RejectionError, error) {
+	rejections := []*RejectionError{}
+	if policy.Wildcard == WildcardExact {
+		return rejections, nil
+	}
+
+	if h.isWildcard(domain) {
+		indexedDomains := h.listIndexedDomains(index)
+		sort.Strings(indexedDomains)
+		for _, indexed := range indexedDomains {
+			if h.wildcardOf(indexed) != domain {
+				continue
+			}
+			conflicts, err := h.lookupConflictingIngresses(index, policy, ingress, domain, indexed)
+			if err != nil {
+				return nil, err
+			}
+			rejections = append(rejections, h.conflictErrors(ingress, domain, indexed, conflicts)...)
+		}
+		return rejections, nil
+	}
+
+	wildcard := h.wildcardOf(domain)
+	if policy.Wildcard == WildcardSpecific || wildcard == "" {
+		return rejections, nil
+	}
+	conflicts, err := h.lookupConflictingIngresses(index, policy, ingress, domain, wildcard)
+	if err != nil {
+		return nil, err
+	}
+	return h.conflictErrors(ingress, domain, wildcard, conflicts), nil
+}
+
 // validateDomainClaims provides a helper function to perform the duplicate domain check
-// in a provider agnostic manner
-func (h *Helper) validateDomainClaims(ingress *v1beta1.Ingress, domains []string) error {
+// in a provider agnostic manner, against the claims of the provider or of its claim group. The DomainClaim
+// reservations are checked before the claims of the other ingresses. All the conflicting domains along with all
+// their owners are reported in a single error. The domains are leased to the ingress once all the checks pass,
+// to settle the claims admitted concurrently by other replicas, and reserved as pending until the informer
+// observes the ingress.
+func (h *Helper) validateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress, domains []string) error {
+	_, err := h.validateClaims(ctx, ingress, domains, nil, false)
+	return err
+}
+
+// ValidateDryRunDomainClaims performs the domain claim checks of the ingress served by the provider p for a
+// dry-run admission, which must not have side effects: the domains are neither leased nor reserved.
+func (h *Helper) ValidateDryRunDomainClaims(ctx context.Context, p Provider, ingress *networkingv1.Ingress) error {
+	_, err := h.validateClaims(ctx, ingress, p.GetDomains(ingress), nil, true)
+	return err
+}
+
+// validateClaims performs the domain claim checks of validateDomainClaims, except that the rejections of the
+// tolerated domains do not fail the checks and are returned instead. The tolerated domains with a rejection
+// are not leased, and for a dry run no domain is leased nor reserved as pending. The leases are acquired
+// outside of the claim lock, the pending reservation holding off the concurrent claims of the replica meanwhile.
+func (h *Helper) validateClaims(ctx context.Context, ingress *networkingv1.Ingress, domains []string,
+	tolerated map[string]bool, dryRun bool) ([]*RejectionError, error) {
+	name := h.GetProvider(ingress).Name()
+	index := h.getClaimIndex(name)
+	policy := h.GetClaimPolicy(name)
+	leased, toleratedRejections, restore, err := h.reserveClaims(index, policy, ingress, domains, tolerated,
+		dryRun)
+	if err != nil {
+		return nil, err
+	}
+	// a dry run must not reserve the domains it would claim
+	if dryRun {
+		return toleratedRejections, nil
+	}
+	if err := h.acquireClaimLeases(ctx, index, policy, ingress, leased); err != nil {
+		restore()
+		return nil, err
+	}
+	return toleratedRejections, nil
+}
+
+// reserveClaims checks the claims of the ingress on the domains under the claim lock, and reserves them as pending
+// unless for a dry run. It returns the domains to lease, the rejections of the tolerated domains and the func
+// restoring the previous reservation of the ingress.
+func (h *Helper) reserveClaims(index string, policy ClaimPolicy, ingress *networkingv1.Ingress, domains []string,
+	tolerated map[string]bool, dryRun bool) ([]string, []*RejectionError, func(), error) {
+	h.claimLock.Lock()
+	defer h.claimLock.Unlock()
+
+	rejections, toleratedRejections := []*RejectionError{}, []*RejectionError{}
+	leased := []string{}
 	for _, domain := range domains {
-		ingressMatches, err := h.lookupIngressesByDomain(h.GetProvider(ingress).Name(), domain)
+		domainRejections, err := h.checkDomainClaim(index, policy, ingress, domain)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 
-		for _, ingressMatch := range ingressMatches {
-			if !(ingressMatch.Namespace == ingress.Namespace && ingressMatch.Name == ingress.Name) {
-				return fmt.Errorf("Domain %s already exists. Ingress %s in namespace %s owns "+
-					"this domain.", domain, ingressMatch.Name, ingressMatch.Namespace)
-			}
+		switch {
+		case len(domainRejections) == 0:
+			leased = append(leased, domain)
+		case tolerated[domain]:
+			toleratedRejections = append(toleratedRejections, domainRejections...)
+		default:
+			rejections = append(rejections, domainRejections...)
+		}
+	}
+	if len(rejections) > 0 {
+		return nil, nil, nil, newRejectionErrors(rejections)
+	}
+	if dryRun {
+		return leased, toleratedRejections, func() {}, nil
+	}
+	return leased, toleratedRejections, h.pending.add(ingress), nil
+}
+
+// checkDomainClaim returns the rejection errors of the claim of the ingress on domain, the DomainClaim
+// reservation of the domain or else all the conflicting claims looked up on the cache index with the name 'index'
+func (h *Helper) checkDomainClaim(index string, policy ClaimPolicy, ingress *networkingv1.Ingress,
+	domain string) ([]*RejectionError, error) {
+	if err := h.validateReservation(ingress, domain); err != nil {
+		rejection, ok := err.(*RejectionError)
+		if !ok {
+			return nil, err
 		}
+		return []*RejectionError{rejection}, nil
+	}
+	conflicts, err := h.lookupConflictingIngresses(index, policy, ingress, domain, domain)
+	if err != nil {
+		return nil, err
+	}
+	rejections := h.conflictErrors(ingress, domain, domain, conflicts)
+
+	wildcardRejections, err := h.validateWildcardClaim(index, policy, ingress, domain)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return append(rejections, wildcardRejections...), nil
 }
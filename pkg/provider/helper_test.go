@@ -3,17 +3,36 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/cache"
 )
 
+// newIstioIngress returns an Istio ingress of the hosts
+func newIstioIngress(namespace string, name string, hosts ...string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Annotations: map[string]string{
+				string(IngressClass): Istio,
+			},
+		},
+	}
+	for _, host := range hosts {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{Host: host})
+	}
+	return ingress
+}
+
 func TestGetDefaultProvider(t *testing.T) {
 	if assert.NotNil(t, helper.GetDefaultProvider(), "should not be nil") {
 		assert.Equal(t, helper.GetDefaultProvider().Name(), ATS, "should return ATS")
@@ -32,7 +51,7 @@ func TestGetProvider(t *testing.T) {
 			ATS,
 		},
 		{
-			"should return ATS provider when annotation set to different provider",
+			"should return the none provider when annotation set to an unknown provider",
 			&v1beta1.Ingress{
 				ObjectMeta: v1.ObjectMeta{
 					Name:      "test-ingress",
@@ -42,7 +61,7 @@ func TestGetProvider(t *testing.T) {
 					},
 				},
 			},
-			ATS,
+			None,
 		},
 		{
 			"should return Istio provider when istio annotation is defined",
@@ -60,7 +79,7 @@ func TestGetProvider(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			p := helper.GetProvider(test.input)
+			p := helper.GetProvider(mustConvert(test.input))
 			if assert.NotNil(t, p, "provider is nil: "+test.name) {
 				assert.Equal(t, p.Name(), test.expected, test.name)
 			}
@@ -200,7 +219,7 @@ func TestLookupIngressesByDomain(t *testing.T) {
 		domain string
 	}
 	type output struct {
-		ingresses [](*v1beta1.Ingress)
+		ingresses [](*networkingv1.Ingress)
 		err       error
 	}
 	tests := []struct {
@@ -226,8 +245,8 @@ func TestLookupIngressesByDomain(t *testing.T) {
 				"test-ref1.xyz.company.com",
 			},
 			output{
-				[](*v1beta1.Ingress){
-					refIng1,
+				[](*networkingv1.Ingress){
+					mustConvert(refIng1),
 				},
 				nil,
 			},
@@ -239,8 +258,8 @@ func TestLookupIngressesByDomain(t *testing.T) {
 				"test-ref2.abc.company.com",
 			},
 			output{
-				[](*v1beta1.Ingress){
-					refIng2,
+				[](*networkingv1.Ingress){
+					mustConvert(refIng2),
 				},
 				nil,
 			},
@@ -252,9 +271,9 @@ func TestLookupIngressesByDomain(t *testing.T) {
 				"test-ref1.abc.company.com",
 			},
 			output{
-				[](*v1beta1.Ingress){
-					refIng1,
-					refIng3,
+				[](*networkingv1.Ingress){
+					mustConvert(refIng1),
+					mustConvert(refIng3),
 				},
 				nil,
 			},
@@ -270,7 +289,7 @@ func TestLookupIngressesByDomain(t *testing.T) {
 			} else {
 				assert.Nil(t, actual.err, "err should be nil: "+test.name)
 			}
-			assert.Equal(t, test.expected.ingresses, actual.ingresses, test.name)
+			assert.ElementsMatch(t, test.expected.ingresses, actual.ingresses, test.name)
 		})
 	}
 }
@@ -511,8 +530,8 @@ func TestValidateDomainClaims(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := helper.validateDomainClaims(test.input,
-				helper.GetProvider(test.input).GetDomains(test.input))
+			ingress := mustConvert(test.input)
+			err := helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
 			if test.expected == nil {
 				assert.Nil(t, err, test.name)
 			} else if assert.NotNil(t, err, test.name) {
@@ -523,3 +542,129 @@ func TestValidateDomainClaims(t *testing.T) {
 	helper.indexer.Delete(refIstioIng)
 	helper.indexer.Delete(refATSIng)
 }
+
+func TestValidateDomainClaimsReportsAllConflicts(t *testing.T) {
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newIstioIngress("team-a", "owner-a", "one.company.com", "two.company.com"))
+	helper.indexer.Add(newIstioIngress("team-b", "owner-b", "two.company.com"))
+	helper.indexer.Add(newIstioIngress("team-c", "owner-c", "*.company.com"))
+
+	ingress := newIstioIngress("test-namespace", "test-ingress", "one.company.com", "two.company.com",
+		"free.other.com", "two.company.com")
+	err := helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
+	if assert.NotNil(t, err, "should fail for the conflicting domains") {
+		assert.Equal(t, "Domain one.company.com already exists. Ingress owner-a in namespace team-a owns this "+
+			"domain. Domain one.company.com overlaps wildcard domain *.company.com. Ingress owner-c in namespace "+
+			"team-c owns this domain. Domain two.company.com already exists. Ingress owner-a in namespace team-a "+
+			"owns this domain. Domain two.company.com already exists. Ingress owner-b in namespace team-b owns "+
+			"this domain. Domain two.company.com overlaps wildcard domain *.company.com. Ingress owner-c in "+
+			"namespace team-c owns this domain.", err.Error(), "should report every conflicting domain and owner")
+
+		rejections := Rejections(err)
+		assert.Len(t, rejections, 5, "should return a rejection per conflicting domain and owner")
+		owners := []string{}
+		for _, rejection := range rejections {
+			owners = append(owners, rejection.Host+"="+rejection.Owner)
+		}
+		assert.Equal(t, []string{"one.company.com=team-a/owner-a", "one.company.com=team-c/owner-c",
+			"two.company.com=team-a/owner-a", "two.company.com=team-b/owner-b", "two.company.com=team-c/owner-c"},
+			owners)
+	}
+}
+
+func TestWildcardOf(t *testing.T) {
+	assert.Equal(t, "*.company.com", helper.wildcardOf("app.company.com"))
+	assert.Equal(t, "*.app.company.com", helper.wildcardOf("api.app.company.com"))
+	assert.Equal(t, "", helper.wildcardOf("localhost"))
+	assert.Equal(t, "", helper.wildcardOf("*.company.com"))
+}
+
+func TestValidateWildcardDomainClaims(t *testing.T) {
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newIstioIngress("test-ns-ref", "test-wildcard-ref", "*.wildcard.company.com"))
+	helper.indexer.Add(newIstioIngress("test-ns-ref", "test-concrete-ref", "api.concrete.company.com"))
+
+	tests := []struct {
+		name     string
+		policy   WildcardPolicy
+		input    *networkingv1.Ingress
+		expected error
+	}{
+		{
+			"should fail for a domain beneath a claimed wildcard with the exclusive policy",
+			WildcardExclusive,
+			newIstioIngress("test-ns-ref", "test-ingress", "api.wildcard.company.com"),
+			errors.New("Domain api.wildcard.company.com overlaps wildcard domain *.wildcard.company.com. " +
+				"Ingress test-wildcard-ref in namespace test-ns-ref owns this domain."),
+		},
+		{
+			"should fail for a wildcard covering a claimed domain with the exclusive policy",
+			WildcardExclusive,
+			newIstioIngress("test-ns-ref", "test-ingress", "*.concrete.company.com"),
+			errors.New("Wildcard domain *.concrete.company.com overlaps domain api.concrete.company.com. " +
+				"Ingress test-concrete-ref in namespace test-ns-ref owns this domain."),
+		},
+		{
+			"should pass for a domain more than one label beneath a claimed wildcard",
+			WildcardExclusive,
+			newIstioIngress("test-ns-ref", "test-ingress", "v1.api.wildcard.company.com",
+				"wildcard.company.com"),
+			nil,
+		},
+		{
+			"should pass for a domain beneath a claimed wildcard with the specific policy",
+			WildcardSpecific,
+			newIstioIngress("test-ns-ref", "test-ingress", "api.wildcard.company.com"),
+			nil,
+		},
+		{
+			"should fail for a wildcard covering a claimed domain with the specific policy",
+			WildcardSpecific,
+			newIstioIngress("test-ns-ref", "test-ingress", "*.concrete.company.com"),
+			errors.New("Wildcard domain *.concrete.company.com overlaps domain api.concrete.company.com. " +
+				"Ingress test-concrete-ref in namespace test-ns-ref owns this domain."),
+		},
+		{
+			"should pass for overlapping wildcards with the exact policy",
+			WildcardExact,
+			newIstioIngress("test-ns-ref", "test-ingress", "api.wildcard.company.com",
+				"*.concrete.company.com"),
+			nil,
+		},
+		{
+			"should fail for the same wildcard with the exact policy",
+			WildcardExact,
+			newIstioIngress("test-ns-ref", "test-ingress", "*.wildcard.company.com"),
+			errors.New("Domain *.wildcard.company.com already exists. Ingress test-wildcard-ref in " +
+				"namespace test-ns-ref owns this domain."),
+		},
+		{
+			"should pass for a wildcard update on the owning ingress",
+			WildcardExclusive,
+			newIstioIngress("test-ns-ref", "test-wildcard-ref", "*.wildcard.company.com",
+				"api.wildcard.company.com"),
+			nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helper.SetClaimPolicy(Istio, ClaimPolicy{Wildcard: test.policy})
+			ingress := test.input
+			err := helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
+			if test.expected == nil {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected.Error(), err.Error(), test.name)
+			}
+		})
+	}
+	helper.SetClaimPolicy(Istio, defaultClaimPolicy)
+}
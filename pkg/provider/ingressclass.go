@@ -0,0 +1,117 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"sort"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultIngressClass is the annotation on IngressClass resources marking the cluster default class
+	DefaultIngressClass Annotation = "ingressclass.kubernetes.io/is-default-class"
+
+	// IstioController is the controller name of the Istio IngressClass resources
+	IstioController = "istio.io/ingress-controller"
+
+	// ATSController is the controller name of the ATS IngressClass resources
+	ATSController = "ats.apache.org/ingress-controller"
+)
+
+// SetIngressClassStore allows to set the IngressClass cache store used to resolve the ingress class names
+// and the cluster default class into providers
+func (h *Helper) SetIngressClassStore(store cache.Store) {
+	h.classes = store
+}
+
+// SetControllers maps the IngressClass controller names to provider names, over the controllers registered by
+// the providers so that the controllers it does not list keep their registered provider
+func (h *Helper) SetControllers(controllers map[string]string) {
+	for controller, name := range controllers {
+		h.controllers[controller] = name
+	}
+}
+
+// getIngressClassName returns the class name of the ingress, the legacy annotation takes precedence over
+// spec.ingressClassName
+func (h *Helper) getIngressClassName(ingress *networkingv1.Ingress) string {
+	if class, exists := ingress.Annotations[string(IngressClass)]; exists {
+		return class
+	}
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+	return ""
+}
+
+// getIngressClass returns the IngressClass resource with the given name, nil if it is not known
+func (h *Helper) getIngressClass(name string) *networkingv1.IngressClass {
+	if h.classes == nil {
+		return nil
+	}
+	obj, exists, err := h.classes.GetByKey(name)
+	if err != nil || !exists {
+		return nil
+	}
+	class, _ := obj.(*networkingv1.IngressClass)
+	return class
+}
+
+// getDefaultIngressClass returns the IngressClass resource marked as the cluster default, nil if there is none.
+// When several classes are marked as default the first one by name is returned.
+func (h *Helper) getDefaultIngressClass() *networkingv1.IngressClass {
+	if h.classes == nil {
+		return nil
+	}
+	defaults := [](*networkingv1.IngressClass){}
+	for _, obj := range h.classes.List() {
+		if class, ok := obj.(*networkingv1.IngressClass); ok && class.Annotations[string(DefaultIngressClass)] == "true" {
+			defaults = append(defaults, class)
+		}
+	}
+	if len(defaults) == 0 {
+		return nil
+	}
+	sort.Slice(defaults, func(i, j int) bool {
+		return defaults[i].Name < defaults[j].Name
+	})
+	return defaults[0]
+}
+
+// getDefaultProviderName returns the provider responsible for ingresses without a class. This is the provider
+// of the cluster default IngressClass controller or, when the cluster has no default class, the configured
+// default provider which defaults to the first enabled provider.
+func (h *Helper) getDefaultProviderName() string {
+	if class := h.getDefaultIngressClass(); class != nil {
+		return h.controllers[class.Spec.Controller]
+	}
+	if h.defaultName != "" {
+		return h.defaultName
+	}
+	if len(h.enabled) == 0 {
+		return None
+	}
+	return h.enabled[0]
+}
+
+// resolveProviderName returns the name of the provider responsible for the given ingress. The class name is
+// resolved through the IngressClass resource controller when such a resource exists, otherwise through the
+// class names mapping or as the provider name itself as with the legacy ingress class annotation.
+func (h *Helper) resolveProviderName(ingress *networkingv1.Ingress) string {
+	if name, indexing := h.indexing.Load(ingress); indexing {
+		return name.(string)
+	}
+	className := h.getIngressClassName(ingress)
+	if className == "" {
+		return h.getDefaultProviderName()
+	}
+	if class := h.getIngressClass(className); class != nil {
+		return h.controllers[class.Spec.Controller]
+	}
+	if name, exists := h.classNames[className]; exists {
+		return name
+	}
+	return className
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newIngressClass(name string, controller string, isDefault bool) *networkingv1.IngressClass {
+	class := &networkingv1.IngressClass{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{},
+		},
+		Spec: networkingv1.IngressClassSpec{
+			Controller: controller,
+		},
+	}
+	if isDefault {
+		class.Annotations[string(DefaultIngressClass)] = "true"
+	}
+	return class
+}
+
+func newClassedIngress(annotation string, className string) *networkingv1.Ingress {
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "test-ingress",
+			Namespace:   "test-namespace",
+			Annotations: map[string]string{},
+		},
+	}
+	if annotation != "" {
+		ingress.Annotations[string(IngressClass)] = annotation
+	}
+	if className != "" {
+		ingress.Spec.IngressClassName = &className
+	}
+	return ingress
+}
+
+func TestResolveProviderName(t *testing.T) {
+	tests := []struct {
+		name     string
+		classes  [](*networkingv1.IngressClass)
+		input    *networkingv1.Ingress
+		expected string
+	}{
+		{
+			"should resolve to ATS when there is no class and no default IngressClass",
+			nil,
+			newClassedIngress("", ""),
+			ATS,
+		},
+		{
+			"should resolve spec.ingressClassName as a provider name when no IngressClass exists",
+			nil,
+			newClassedIngress("", Istio),
+			Istio,
+		},
+		{
+			"should resolve spec.ingressClassName through the IngressClass controller",
+			[](*networkingv1.IngressClass){
+				newIngressClass("public", IstioController, false),
+			},
+			newClassedIngress("", "public"),
+			Istio,
+		},
+		{
+			"should prefer the class annotation over spec.ingressClassName",
+			[](*networkingv1.IngressClass){
+				newIngressClass("public", IstioController, false),
+			},
+			newClassedIngress(ATS, "public"),
+			ATS,
+		},
+		{
+			"should resolve an ingress without class to the default IngressClass provider",
+			[](*networkingv1.IngressClass){
+				newIngressClass("internal", ATSController, false),
+				newIngressClass("public", IstioController, true),
+			},
+			newClassedIngress("", ""),
+			Istio,
+		},
+		{
+			"should resolve to no provider for an IngressClass with an unknown controller",
+			[](*networkingv1.IngressClass){
+				newIngressClass("other", "example.com/ingress-controller", true),
+			},
+			newClassedIngress("", "other"),
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+			for _, class := range test.classes {
+				store.Add(class)
+			}
+			helper.SetIngressClassStore(store)
+			assert.Equal(t, test.expected, helper.resolveProviderName(test.input), test.name)
+		})
+	}
+	helper.SetIngressClassStore(nil)
+}
+
+func TestGetDefaultProviderFromIngressClass(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(newIngressClass("public", IstioController, true))
+	helper.SetIngressClassStore(store)
+
+	assert.Equal(t, Istio, helper.GetDefaultProvider().Name(), "should return the default IngressClass provider")
+	assert.Equal(t, Istio, helper.GetProvider(newClassedIngress("", "")).Name(),
+		"should serve an ingress without class with the default IngressClass provider")
+	assert.False(t, a.ServesIngress(newClassedIngress("", "")),
+		"ATS should not serve an ingress without class when the default IngressClass is istio")
+
+	helper.SetIngressClassStore(nil)
+}
+
+func TestSetControllers(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+		store.Add(newIngressClass("custom", "example.com/ats-controller", false))
+		store.Add(newIngressClass("mesh", IstioController, false))
+		helper.SetIngressClassStore(store)
+
+		helper.SetControllers(map[string]string{"example.com/ats-controller": ATS})
+		assert.Equal(t, ATS, helper.ResolveProviderName(newClassedIngress("", "custom")),
+			"should resolve the class of a listed controller to its provider")
+		assert.Equal(t, Istio, helper.ResolveProviderName(newClassedIngress("", "mesh")),
+			"should keep the registered controllers not listed")
+	})
+}
+
+func TestLookupIngressClassChange(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	helper.SetIngressClassStore(store)
+	defer helper.SetIngressClassStore(nil)
+	helper.SetIndexer(cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, helper.GetIndexers()))
+
+	classed := newClassedIngress("", "public")
+	classed.Spec.Rules = []networkingv1.IngressRule{{Host: "classed.company.com"}}
+	unclassed := newClassedIngress("", "")
+	unclassed.Name = "unclassed-ingress"
+	unclassed.Spec.Rules = []networkingv1.IngressRule{{Host: "unclassed.company.com"}}
+	helper.indexer.Add(classed)
+	helper.indexer.Add(unclassed)
+	indexed := func(index string, domain string) bool {
+		ingresses, err := helper.lookupIngressesByDomain(index, domain)
+		return err == nil && len(ingresses) == 1
+	}
+	assert.False(t, indexed(Istio, "classed.company.com"), "should not match an ingress of an unknown class")
+
+	store.Add(newIngressClass("public", IstioController, true))
+	assert.True(t, indexed(Istio, "classed.company.com"), "should match the ingresses of the added class")
+	assert.True(t, indexed(Istio, "unclassed.company.com"),
+		"should match the ingresses without class of the added default class")
+
+	store.Delete(newIngressClass("public", IstioController, true))
+	assert.False(t, indexed(Istio, "classed.company.com"), "should not match the ingresses of the deleted class")
+	assert.False(t, indexed(Istio, "unclassed.company.com"),
+		"should not match the ingresses without class of the deleted default class")
+}
@@ -3,9 +3,8 @@
 package provider
 
 import (
-	"errors"
-
-	"k8s.io/api/extensions/v1beta1"
+	"context"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 const (
@@ -14,6 +13,11 @@ const (
 
 type istio struct{}
 
+// init registers the provider along with its IngressClass controller
+func init() {
+	Register(NewIstioProvider(), IstioController)
+}
+
 // NewIstioProvider returns a new istio provider ref that implements Provider interface
 func NewIstioProvider() *istio {
 	return &istio{}
@@ -25,13 +29,12 @@ func (i *istio) Name() string {
 }
 
 // ServesIngress checks if the given ingress falls under Istio provider class
-func (i *istio) ServesIngress(ingress *v1beta1.Ingress) bool {
-	class, exists := ingress.Annotations[string(IngressClass)]
-	return exists && class == Istio
+func (i *istio) ServesIngress(ingress *networkingv1.Ingress) bool {
+	return helper.resolveProviderName(ingress) == Istio
 }
 
 // GetDomains returns the list of hosts associated with rules for the Istio ingress
-func (i *istio) GetDomains(ingress *v1beta1.Ingress) []string {
+func (i *istio) GetDomains(ingress *networkingv1.Ingress) []string {
 	hosts := []string{}
 	if i.ServesIngress(ingress) {
 		for _, rule := range ingress.Spec.Rules {
@@ -43,9 +46,9 @@ func (i *istio) GetDomains(ingress *v1beta1.Ingress) []string {
 
 // DomainsIndexFunc returns the list of hosts claimed by the given Istio ingress
 func (i *istio) DomainsIndexFunc(obj interface{}) ([]string, error) {
-	ingress, ok := obj.(*v1beta1.Ingress)
-	if !ok {
-		return nil, errors.New("Resource is not an Ingress kind.")
+	ingress, err := ToIngress(obj)
+	if err != nil {
+		return nil, err
 	}
 	if i.ServesIngress(ingress) {
 		return i.GetDomains(ingress), nil
@@ -54,30 +57,47 @@ func (i *istio) DomainsIndexFunc(obj interface{}) ([]string, error) {
 }
 
 // ValidateSemantics performs Istio specific validation checks
-func (i *istio) ValidateSemantics(ingress *v1beta1.Ingress) error {
+func (i *istio) ValidateSemantics(ingress *networkingv1.Ingress) error {
 	if i.ServesIngress(ingress) {
-		if ingress.Spec.Backend != nil {
-			return errors.New("Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
-				" specifies a default backend which is currently NOT supported for provider class: " +
-				Istio)
+		if ingress.Spec.DefaultBackend != nil {
+			return &RejectionError{
+				Reason: ReasonInvalidBackend,
+				Message: "Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
+					" specifies a default backend which is currently NOT supported for provider class: " +
+					Istio,
+				Field: "spec.defaultBackend",
+			}
 		}
 
-		for _, rule := range ingress.Spec.Rules {
+		for index, rule := range ingress.Spec.Rules {
 			if helper.sanitize(rule.Host) == "" {
-				return errors.New("Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
-					" specifies an IngressRule without a Host which is currently NOT supported " +
-					"for provider class: " + Istio)
+				return &RejectionError{
+					Reason: ReasonInvalidRule,
+					Message: "Ingress " + ingress.Name + " in namespace " + ingress.Namespace +
+						" specifies an IngressRule without a Host which is currently NOT supported " +
+						"for provider class: " + Istio,
+					Field: helper.ruleHostField(index),
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// GetWarnings returns the common findings on the Istio ingress, the rule hosts are validated lowercase by the
+// api server already
+func (i *istio) GetWarnings(ingress *networkingv1.Ingress) []string {
+	if i.ServesIngress(ingress) {
+		return helper.getCommonWarnings(ingress)
+	}
+	return []string{}
+}
+
 // ValidateDomainClaims checks if the ingress attempts to claim a "Host" that has already been claimed
-func (i *istio) ValidateDomainClaims(ingress *v1beta1.Ingress) error {
+func (i *istio) ValidateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress) error {
 	if i.ServesIngress(ingress) {
 		domains := i.GetDomains(ingress)
-		return helper.validateDomainClaims(ingress, domains)
+		return helper.validateDomainClaims(ctx, ingress, domains)
 	}
 	return nil
 }
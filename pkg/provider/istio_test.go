@@ -3,6 +3,7 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -76,7 +77,7 @@ func TestIstioServesIngress(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			assert.Equal(t, i.ServesIngress(test.input), test.expected, test.name)
+			assert.Equal(t, i.ServesIngress(mustConvert(test.input)), test.expected, test.name)
 		})
 	}
 }
@@ -149,7 +150,7 @@ func TestIstioGetDomains(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			assert.Equal(t, test.expected, i.GetDomains(test.input), test.name)
+			assert.Equal(t, test.expected, i.GetDomains(mustConvert(test.input)), test.name)
 		})
 	}
 }
@@ -346,7 +347,7 @@ func TestIstioValidateSemantics(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := i.ValidateSemantics(test.input)
+			err := i.ValidateSemantics(mustConvert(test.input))
 			if test.expected == nil {
 				assert.Nil(t, err, test.name)
 			} else if assert.NotNil(t, err, test.name) {
@@ -356,6 +357,26 @@ func TestIstioValidateSemantics(t *testing.T) {
 	}
 }
 
+func TestIstioGetWarnings(t *testing.T) {
+	className := Istio
+	ingress := &v1beta1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				string(IngressClass): Istio,
+			},
+		},
+	}
+	assert.Equal(t, []string{"Annotation kubernetes.io/ingress.class is deprecated, use spec.ingressClassName " +
+		"instead."}, i.GetWarnings(mustConvert(ingress)), "should warn about the deprecated class annotation")
+
+	ingress = &v1beta1.Ingress{
+		Spec: v1beta1.IngressSpec{
+			IngressClassName: &className,
+		},
+	}
+	assert.Equal(t, []string{}, i.GetWarnings(mustConvert(ingress)), "should return empty for a class name")
+}
+
 func TestIstioValidateDomainClaims(t *testing.T) {
 
 	refIng := &v1beta1.Ingress{
@@ -529,7 +550,7 @@ func TestIstioValidateDomainClaims(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := i.ValidateDomainClaims(test.input)
+			err := i.ValidateDomainClaims(context.TODO(), mustConvert(test.input))
 			if test.expected == nil {
 				assert.Nil(t, err, test.name)
 			} else if assert.NotNil(t, err, test.name) {
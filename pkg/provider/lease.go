@@ -0,0 +1,352 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+const (
+	// ClaimLeaseAnnotation is the annotation on the claim leases holding the claimed domain
+	ClaimLeaseAnnotation Annotation = "ingressclaim.yahoo.io/claim"
+
+	// claimLeasePrefix prefixes the names of the claim leases
+	claimLeasePrefix = "ingress-claim-"
+
+	// claimLeaseLabel labels the claim leases for the expired ones to be collected
+	claimLeaseLabel = "ingressclaim.yahoo.io/claim-lease"
+
+	// claimWildcardLabel labels the claim leases of the domains beneath a wildcard with the hash of the wildcard
+	claimWildcardLabel = "ingressclaim.yahoo.io/claim-wildcard"
+
+	// claimLeaseAttempts is the number of attempts to acquire a lease raced by another replica
+	claimLeaseAttempts = 3
+)
+
+// ClaimLeases reserves the domains of the admitted ingresses through coordination.k8s.io Leases, so that the
+// replicas of the webhook agree on the first claim of a domain before their informer caches observe its ingress.
+// A lease is held by the claim owner for the lease duration and only blocks the claims of the other owners as
+// long as the ingress of its holder is not in the informer cache.
+type ClaimLeases struct {
+	client   coordinationclient.LeaseInterface
+	duration time.Duration
+}
+
+// NewClaimLeases returns the claim leases managed through the given namespaced Lease client, held for the
+// given duration
+func NewClaimLeases(client coordinationclient.LeaseInterface, duration time.Duration) *ClaimLeases {
+	return &ClaimLeases{
+		client:   client,
+		duration: duration,
+	}
+}
+
+// SetClaimLeases allows to set the claim leases reserving the admitted domains across the replicas, the leases
+// are not consulted as long as it is not set
+func (h *Helper) SetClaimLeases(leases *ClaimLeases) {
+	h.leases = leases
+}
+
+// leaseHash returns the hash of the claim key naming its lease
+func (l *ClaimLeases) leaseHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:40]
+}
+
+// leaseName returns the name of the lease of the claim key
+func (l *ClaimLeases) leaseName(key string) string {
+	return claimLeasePrefix + l.leaseHash(key)
+}
+
+// leaseLabels returns the labels of the lease of the claimed domain, a domain beneath a wildcard is labelled
+// with the hash of the wildcard for the claims of the wildcard to list the leases it covers
+func (l *ClaimLeases) leaseLabels(domain string) map[string]string {
+	labels := map[string]string{claimLeaseLabel: "true"}
+	if wildcard := helper.wildcardOf(domain); wildcard != "" {
+		labels[claimWildcardLabel] = l.leaseHash(wildcard)
+	}
+	return labels
+}
+
+// expired checks if the lease has not been renewed within its duration
+func (l *ClaimLeases) expired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	duration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	return time.Now().After(lease.Spec.RenewTime.Add(duration))
+}
+
+// holderOf returns the holder of the lease, "" once expired
+func (l *ClaimLeases) holderOf(lease *coordinationv1.Lease) string {
+	if l.expired(lease) || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// acquire acquires or renews the lease of the claimed domain for the owner. A lease held by another owner is
+// taken over once expired or when takeover allows it, otherwise its holder is returned. acquired is true when
+// the lease was not held by the owner before.
+func (l *ClaimLeases) acquire(ctx context.Context, domain string, owner string, takeover func(holder string) bool) (
+	holder string, acquired bool, err error) {
+	name := l.leaseName(domain)
+	seconds := int32(l.duration / time.Second)
+	for attempt := 0; attempt < claimLeaseAttempts; attempt++ {
+		now := v1.NewMicroTime(time.Now())
+		lease, err := l.client.Get(ctx, name, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			lease = &coordinationv1.Lease{
+				ObjectMeta: v1.ObjectMeta{
+					Name:        name,
+					Labels:      l.leaseLabels(domain),
+					Annotations: map[string]string{string(ClaimLeaseAnnotation): domain},
+				},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       &owner,
+					LeaseDurationSeconds: &seconds,
+					AcquireTime:          &now,
+					RenewTime:            &now,
+				},
+			}
+			_, err = l.client.Create(ctx, lease, v1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return "", err == nil, err
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		lease = lease.DeepCopy()
+		holder := ""
+		if lease.Spec.HolderIdentity != nil {
+			holder = *lease.Spec.HolderIdentity
+		}
+		if holder != owner {
+			if !l.expired(lease) && !takeover(holder) {
+				return holder, false, nil
+			}
+			transitions := int32(1)
+			if lease.Spec.LeaseTransitions != nil {
+				transitions += *lease.Spec.LeaseTransitions
+			}
+			lease.Spec.HolderIdentity = &owner
+			lease.Spec.AcquireTime = &now
+			lease.Spec.LeaseTransitions = &transitions
+		}
+		lease.Labels = l.leaseLabels(domain)
+		lease.Spec.LeaseDurationSeconds = &seconds
+		lease.Spec.RenewTime = &now
+		_, err = l.client.Update(ctx, lease, v1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return "", err == nil && holder != owner, err
+	}
+	return "", false, fmt.Errorf("Unable to acquire the lease %s of the claim %s, too many concurrent updates",
+		name, domain)
+}
+
+// release deletes the lease of the claimed domain as long as it is still held by the owner
+func (l *ClaimLeases) release(ctx context.Context, domain string, owner string) error {
+	lease, err := l.client.Get(ctx, l.leaseName(domain), v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != owner {
+		return nil
+	}
+	return l.delete(ctx, lease)
+}
+
+// delete deletes the lease unless it was updated since it was read
+func (l *ClaimLeases) delete(ctx context.Context, lease *coordinationv1.Lease) error {
+	err := l.client.Delete(ctx, lease.Name, v1.DeleteOptions{
+		Preconditions: &v1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// holder returns the holder of the unexpired lease of the claimed domain, "" when the domain is not leased
+func (l *ClaimLeases) holder(ctx context.Context, domain string) (string, error) {
+	lease, err := l.client.Get(ctx, l.leaseName(domain), v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return l.holderOf(lease), nil
+}
+
+// holdersBeneath returns the holders of the unexpired leases of the domains beneath the wildcard, by domain
+func (l *ClaimLeases) holdersBeneath(ctx context.Context, wildcard string) (map[string]string, error) {
+	leases, err := l.client.List(ctx, v1.ListOptions{
+		LabelSelector: claimWildcardLabel + "=" + l.leaseHash(wildcard),
+	})
+	if err != nil {
+		return nil, err
+	}
+	holders := map[string]string{}
+	for i := range leases.Items {
+		domain := leases.Items[i].Annotations[string(ClaimLeaseAnnotation)]
+		if holder := l.holderOf(&leases.Items[i]); holder != "" && helper.wildcardOf(domain) == wildcard {
+			holders[domain] = holder
+		}
+	}
+	return holders, nil
+}
+
+// Collect deletes the expired claim leases, whose holders stopped claiming their domains for the lease duration,
+// all the expired leases are collected before returning the number of leases failing to be deleted
+func (l *ClaimLeases) Collect(ctx context.Context) error {
+	leases, err := l.client.List(ctx, v1.ListOptions{LabelSelector: claimLeaseLabel + "=true"})
+	if err != nil {
+		return fmt.Errorf("Unable to list the claim leases: %s", err.Error())
+	}
+	failed := 0
+	for i := range leases.Items {
+		if !l.expired(&leases.Items[i]) {
+			continue
+		}
+		if err := l.delete(ctx, &leases.Items[i]); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Unable to delete %d expired claim leases", failed)
+	}
+	return nil
+}
+
+// isClaimObserved checks if an ingress of the owner claiming the domain is in the cache index with the name
+// 'index', in which case the domain claim checks on the cache already arbitrated between the owners
+func (h *Helper) isClaimObserved(index string, policy ClaimPolicy, domain string, owner string) bool {
+	ingresses, err := h.lookupIngressesByDomain(index, domain)
+	if err != nil {
+		return false
+	}
+	for _, ingress := range ingresses {
+		if h.getClaimOwner(policy, ingress) == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// getOverlappingHolders returns the holders of the unexpired leases of the domains overlapping the domain through
+// a wildcard according to the wildcard policy, by domain: the domains beneath a wildcard, or the wildcard covering
+// a domain with the exclusive policy
+func (h *Helper) getOverlappingHolders(ctx context.Context, policy ClaimPolicy, domain string) (map[string]string,
+	error) {
+	if policy.Wildcard == WildcardExact {
+		return nil, nil
+	}
+	if h.isWildcard(domain) {
+		return h.leases.holdersBeneath(ctx, domain)
+	}
+	wildcard := h.wildcardOf(domain)
+	if policy.Wildcard != WildcardExclusive || wildcard == "" {
+		return nil, nil
+	}
+	holder, err := h.leases.holder(ctx, wildcard)
+	if err != nil || holder == "" {
+		return nil, err
+	}
+	return map[string]string{wildcard: holder}, nil
+}
+
+// acquireClaimLeases acquires the claim leases of the ingress on the domains, the leases are held per domain
+// whatever the claim granularity, so the claims of different paths of a domain are also settled by the lease. It
+// fails for the first domain leased, or overlapped through a wildcard, by another owner whose ingress has not been
+// observed yet, releasing the leases acquired on the way. The lease calls are bounded by ctx, failing the claim once
+// it is done, and the leases it could not release expire with the lease duration.
+func (h *Helper) acquireClaimLeases(ctx context.Context, index string, policy ClaimPolicy,
+	ingress *networkingv1.Ingress, domains []string) error {
+	if h.leases == nil {
+		return nil
+	}
+	owner := h.getClaimOwner(policy, ingress)
+	acquired := []string{}
+	err := h.leaseDomains(ctx, index, policy, ingress, owner, domains, &acquired)
+	if err != nil {
+		for _, domain := range acquired {
+			h.leases.release(ctx, domain, owner)
+		}
+	}
+	return err
+}
+
+// leaseDomains leases the domains to the owner on behalf of acquireClaimLeases, recording the leases it acquired
+func (h *Helper) leaseDomains(ctx context.Context, index string, policy ClaimPolicy, ingress *networkingv1.Ingress,
+	owner string, domains []string, acquired *[]string) error {
+	for _, domain := range domains {
+		takeover := func(holder string) bool {
+			return h.isClaimObserved(index, policy, domain, holder)
+		}
+		holder, isAcquired, err := h.leases.acquire(ctx, domain, owner, takeover)
+		if err != nil {
+			return fmt.Errorf("Unable to reserve domain %s: %s", domain, err.Error())
+		}
+		if isAcquired {
+			*acquired = append(*acquired, domain)
+		}
+		if holder != "" {
+			return h.leasedRejection(ingress, domain, domain, holder)
+		}
+
+		holders, err := h.getOverlappingHolders(ctx, policy, domain)
+		if err != nil {
+			return fmt.Errorf("Unable to reserve domain %s: %s", domain, err.Error())
+		}
+		overlapping := []string{}
+		for leased, holder := range holders {
+			if holder != owner && !h.isClaimObserved(index, policy, leased, holder) {
+				overlapping = append(overlapping, leased)
+			}
+		}
+		if len(overlapping) > 0 {
+			sort.Strings(overlapping)
+			return h.leasedRejection(ingress, domain, overlapping[0], holders[overlapping[0]])
+		}
+	}
+	return nil
+}
+
+// leasedRejection returns the rejection of the claim of the ingress on the domain, as the leased domain is being
+// claimed concurrently by the holder
+func (h *Helper) leasedRejection(ingress *networkingv1.Ingress, domain string, leased string,
+	holder string) *RejectionError {
+	message := fmt.Sprintf("Domain %s is being claimed concurrently by %s. Retry once it is admitted.", domain,
+		holder)
+	if leased != domain {
+		message = fmt.Sprintf("Domain %s overlaps domain %s being claimed concurrently by %s. Retry once it is "+
+			"admitted.", domain, leased, holder)
+	}
+	return &RejectionError{
+		Reason:  ReasonClaimPending,
+		Message: message,
+		Field:   h.domainField(ingress, domain),
+		Host:    domain,
+		Owner:   holder,
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// withPaths returns the ingress routing the paths on its first rule
+func withPaths(ingress *networkingv1.Ingress, paths ...string) *networkingv1.Ingress {
+	ingress.Spec.Rules[0].HTTP = &networkingv1.HTTPIngressRuleValue{}
+	for _, path := range paths {
+		ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths,
+			networkingv1.HTTPIngressPath{Path: path})
+	}
+	return ingress
+}
+
+func TestValidateLeasedDomainClaims(t *testing.T) {
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		})
+	helper.SetIndexer(indexer)
+	client := fake.NewSimpleClientset().CoordinationV1().Leases("test-namespace")
+	leases := NewClaimLeases(client, time.Minute)
+	helper.SetClaimLeases(leases)
+	defer helper.SetClaimLeases(nil)
+
+	first := newIstioIngress("test-namespace", "first-ingress", "test.company.com")
+	second := newIstioIngress("test-namespace", "second-ingress", "test.company.com")
+	validate := func(ingress *networkingv1.Ingress) error {
+		return helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
+	}
+
+	assert.Nil(t, validate(first), "should lease an unclaimed domain")
+	lease, err := client.Get(context.TODO(), leases.leaseName("test.company.com"), v1.GetOptions{})
+	if assert.Nil(t, err, "should create the lease") {
+		assert.Equal(t, "ingress:test-namespace/first-ingress", *lease.Spec.HolderIdentity)
+		assert.Equal(t, int32(60), *lease.Spec.LeaseDurationSeconds)
+		assert.Equal(t, "test.company.com", lease.Annotations[string(ClaimLeaseAnnotation)])
+	}
+
+	err = validate(second)
+	if assert.NotNil(t, err, "should fail for a domain leased by another owner not observed yet") {
+		assert.Equal(t, "Domain test.company.com is being claimed concurrently by "+
+			"ingress:test-namespace/first-ingress. Retry once it is admitted.", err.Error())
+	}
+	assert.Nil(t, validate(first), "should renew the lease of the holder")
+
+	indexer.Add(first)
+	assert.Nil(t, helper.acquireClaimLeases(context.TODO(), Istio, defaultClaimPolicy, second,
+		[]string{"test.company.com"}), "should take over a lease once the ingress of the holder is observed")
+	lease, err = client.Get(context.TODO(), leases.leaseName("test.company.com"), v1.GetOptions{})
+	if assert.Nil(t, err, "err should be nil") {
+		assert.Equal(t, "ingress:test-namespace/second-ingress", *lease.Spec.HolderIdentity)
+		assert.Equal(t, int32(1), *lease.Spec.LeaseTransitions)
+	}
+	indexer.Delete(first)
+
+	holder, seconds := "ingress:test-namespace/other-ingress", int32(60)
+	renewTime := v1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+	_, err = client.Create(context.TODO(), &coordinationv1.Lease{
+		ObjectMeta: v1.ObjectMeta{
+			Name: leases.leaseName("expired.company.com"),
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &renewTime,
+		},
+	}, v1.CreateOptions{})
+	assert.Nil(t, err, "err should be nil")
+	assert.Nil(t, validate(newIstioIngress("test-namespace", "first-ingress", "expired.company.com")),
+		"should take over an expired lease")
+
+	dryRun := newIstioIngress("test-namespace", "first-ingress", "dry-run.company.com")
+	assert.Nil(t, helper.ValidateDryRunDomainClaims(context.TODO(), helper.GetProvider(dryRun), dryRun),
+		"err should be nil")
+	_, err = client.Get(context.TODO(), leases.leaseName("dry-run.company.com"), v1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "should not lease the domains of a dry run")
+}
+
+func TestValidateLeasedOverlappingClaims(t *testing.T) {
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		})
+	helper.SetIndexer(indexer)
+	client := fake.NewSimpleClientset().CoordinationV1().Leases("test-namespace")
+	leases := NewClaimLeases(client, time.Minute)
+	helper.SetClaimLeases(leases)
+	defer helper.SetClaimLeases(nil)
+
+	tests := []struct {
+		name     string
+		policy   ClaimPolicy
+		first    *networkingv1.Ingress
+		second   *networkingv1.Ingress
+		expected string
+	}{
+		{
+			"should lease the host of the path claims",
+			ClaimPolicy{Granularity: GranularityPath, Wildcard: WildcardExclusive},
+			withPaths(newIstioIngress("test-namespace", "first-ingress", "path.company.com"), "/"),
+			withPaths(newIstioIngress("test-namespace", "second-ingress", "path.company.com"), "/api"),
+			"Domain path.company.com is being claimed concurrently by ingress:test-namespace/first-ingress. " +
+				"Retry once it is admitted.",
+		},
+		{
+			"should fail for a wildcard covering a leased domain",
+			ClaimPolicy{Granularity: GranularityHost, Wildcard: WildcardSpecific},
+			newIstioIngress("test-namespace", "first-ingress", "api.specific.com"),
+			newIstioIngress("test-namespace", "second-ingress", "*.specific.com"),
+			"Domain *.specific.com overlaps domain api.specific.com being claimed concurrently by " +
+				"ingress:test-namespace/first-ingress. Retry once it is admitted.",
+		},
+		{
+			"should fail for a domain beneath a leased wildcard with the exclusive policy",
+			ClaimPolicy{Granularity: GranularityHost, Wildcard: WildcardExclusive},
+			newIstioIngress("test-namespace", "first-ingress", "*.exclusive.com"),
+			newIstioIngress("test-namespace", "second-ingress", "api.exclusive.com"),
+			"Domain api.exclusive.com overlaps domain *.exclusive.com being claimed concurrently by " +
+				"ingress:test-namespace/first-ingress. Retry once it is admitted.",
+		},
+		{
+			"should allow a domain beneath a leased wildcard with the specific policy",
+			ClaimPolicy{Granularity: GranularityHost, Wildcard: WildcardSpecific},
+			newIstioIngress("test-namespace", "first-ingress", "*.allowed.com"),
+			newIstioIngress("test-namespace", "second-ingress", "api.allowed.com"),
+			"",
+		},
+		{
+			"should allow a wildcard covering a leased domain with the exact policy",
+			ClaimPolicy{Granularity: GranularityHost, Wildcard: WildcardExact},
+			newIstioIngress("test-namespace", "first-ingress", "api.exact.com"),
+			newIstioIngress("test-namespace", "second-ingress", "*.exact.com"),
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			domain := test.second.Spec.Rules[0].Host
+			assert.Nil(t, helper.acquireClaimLeases(context.TODO(), Istio, test.policy, test.first,
+				[]string{test.first.Spec.Rules[0].Host}), "err should be nil")
+			err := helper.acquireClaimLeases(context.TODO(), Istio, test.policy, test.second, []string{domain})
+			if test.expected == "" {
+				assert.Nil(t, err, "err should be nil")
+				return
+			}
+			if assert.NotNil(t, err, "should fail for the overlapping lease") {
+				assert.Equal(t, test.expected, err.Error())
+			}
+			if domain != test.first.Spec.Rules[0].Host {
+				_, err = client.Get(context.TODO(), leases.leaseName(domain), v1.GetOptions{})
+				assert.True(t, apierrors.IsNotFound(err), "should release the lease of the rejected domain")
+			}
+		})
+	}
+}
+
+func TestCollectClaimLeases(t *testing.T) {
+	client := fake.NewSimpleClientset().CoordinationV1().Leases("test-namespace")
+	leases := NewClaimLeases(client, time.Minute)
+	notTakenOver := func(holder string) bool { return false }
+	for _, domain := range []string{"expired.company.com", "held.company.com"} {
+		_, _, err := leases.acquire(context.TODO(), domain, "ingress:test-namespace/test-ingress", notTakenOver)
+		assert.Nil(t, err, "err should be nil")
+	}
+	expired, err := client.Get(context.TODO(), leases.leaseName("expired.company.com"), v1.GetOptions{})
+	if assert.Nil(t, err, "err should be nil") {
+		renewTime := v1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+		expired.Spec.RenewTime = &renewTime
+		_, err = client.Update(context.TODO(), expired, v1.UpdateOptions{})
+		assert.Nil(t, err, "err should be nil")
+	}
+
+	assert.Nil(t, leases.Collect(context.TODO()), "err should be nil")
+	_, err = client.Get(context.TODO(), leases.leaseName("expired.company.com"), v1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "should delete the expired lease")
+	_, err = client.Get(context.TODO(), leases.leaseName("held.company.com"), v1.GetOptions{})
+	assert.Nil(t, err, "should keep the lease held")
+}
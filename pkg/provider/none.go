@@ -0,0 +1,54 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// None is the name of the provider of the ingresses served by no provider, whose checks are skipped
+	None = "none"
+)
+
+type none struct{}
+
+// noneProvider is the provider of the ingresses not served by any enabled provider
+var noneProvider Provider = &none{}
+
+// Name returns "none"
+func (n *none) Name() string {
+	return None
+}
+
+// ServesIngress checks if the given ingress is explicitly served by no provider, through a class resolved to
+// the "none" provider
+func (n *none) ServesIngress(ingress *networkingv1.Ingress) bool {
+	return helper.resolveProviderName(ingress) == None
+}
+
+// GetDomains returns no domains, the ingresses served by no provider claim no domains
+func (n *none) GetDomains(ingress *networkingv1.Ingress) []string {
+	return []string{}
+}
+
+// DomainsIndexFunc returns no domains
+func (n *none) DomainsIndexFunc(obj interface{}) ([]string, error) {
+	return []string{}, nil
+}
+
+// ValidateSemantics skips the validation checks
+func (n *none) ValidateSemantics(ingress *networkingv1.Ingress) error {
+	return nil
+}
+
+// ValidateDomainClaims skips the domain claim checks
+func (n *none) ValidateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress) error {
+	return nil
+}
+
+// GetWarnings returns no warnings
+func (n *none) GetWarnings(ingress *networkingv1.Ingress) []string {
+	return []string{}
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// pathClaim is a path routed by an ingress on a host
+type pathClaim struct {
+	path  string
+	exact bool
+}
+
+// getHostPaths returns the paths the ingress routes on the host. A host without http paths, or a domain not
+// listed on the rules such as the ATS annotation domains, is routed as a whole through the "/" prefix.
+// Paths of the ImplementationSpecific type are handled as prefixes.
+func (h *Helper) getHostPaths(ingress *networkingv1.Ingress, host string) []pathClaim {
+	paths := []pathClaim{}
+	for _, rule := range ingress.Spec.Rules {
+		if h.sanitize(rule.Host) != host || rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			claim := pathClaim{
+				path:  path.Path,
+				exact: path.PathType != nil && *path.PathType == networkingv1.PathTypeExact,
+			}
+			if claim.path == "" {
+				claim.path = "/"
+			}
+			paths = append(paths, claim)
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, pathClaim{path: "/"})
+	}
+	return paths
+}
+
+// pathHasPrefix checks if the path matches the prefix element-wise as for the Prefix path type, i.e.
+// /foo/bar matches /foo but /foobar does not
+func (h *Helper) pathHasPrefix(path string, prefix string) bool {
+	prefixElements := strings.Split(strings.Trim(prefix, "/"), "/")
+	if len(prefixElements) == 1 && prefixElements[0] == "" {
+		return true
+	}
+	pathElements := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pathElements) < len(prefixElements) {
+		return false
+	}
+	for i, element := range prefixElements {
+		if pathElements[i] != element {
+			return false
+		}
+	}
+	return true
+}
+
+// pathOverlaps checks if any request could be routed by both path claims
+func (h *Helper) pathOverlaps(a pathClaim, b pathClaim) bool {
+	switch {
+	case a.exact && b.exact:
+		return a.path == b.path
+	case a.exact:
+		return h.pathHasPrefix(a.path, b.path)
+	case b.exact:
+		return h.pathHasPrefix(b.path, a.path)
+	}
+	return h.pathHasPrefix(a.path, b.path) || h.pathHasPrefix(b.path, a.path)
+}
+
+// pathsOverlap checks if any of the paths overlaps any of the owner paths and returns the first overlapping path
+func (h *Helper) pathsOverlap(paths []pathClaim, ownerPaths []pathClaim) (string, bool) {
+	for _, path := range paths {
+		for _, ownerPath := range ownerPaths {
+			if h.pathOverlaps(path, ownerPath) {
+				return path.path, true
+			}
+		}
+	}
+	return "", false
+}
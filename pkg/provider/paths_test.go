@@ -0,0 +1,148 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPathHasPrefix(t *testing.T) {
+	tests := []struct {
+		path     string
+		prefix   string
+		expected bool
+	}{
+		{"/foo", "/", true},
+		{"/foo/bar", "/foo", true},
+		{"/foo/bar/", "/foo/", true},
+		{"/foo", "/foo/", true},
+		{"/foobar", "/foo", false},
+		{"/foo", "/foo/bar", false},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			assert.Equal(t, test.expected, helper.pathHasPrefix(test.path, test.prefix))
+		})
+	}
+}
+
+func TestPathOverlaps(t *testing.T) {
+	tests := []struct {
+		a        pathClaim
+		b        pathClaim
+		expected bool
+	}{
+		{pathClaim{"/api", false}, pathClaim{"/web", false}, false},
+		{pathClaim{"/api", false}, pathClaim{"/api/v1", false}, true},
+		{pathClaim{"/", false}, pathClaim{"/web", false}, true},
+		{pathClaim{"/api", true}, pathClaim{"/api/", true}, false},
+		{pathClaim{"/api", true}, pathClaim{"/api", true}, true},
+		{pathClaim{"/api/v1", true}, pathClaim{"/api", false}, true},
+		{pathClaim{"/api", true}, pathClaim{"/api/v1", false}, false},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			assert.Equal(t, test.expected, helper.pathOverlaps(test.a, test.b))
+			assert.Equal(t, test.expected, helper.pathOverlaps(test.b, test.a))
+		})
+	}
+}
+
+func TestValidatePathDomainClaims(t *testing.T) {
+	exact := networkingv1.PathTypeExact
+	prefix := networkingv1.PathTypePrefix
+	newPathIngress := func(name string, host string, pathType networkingv1.PathType,
+		paths ...string) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-ns-ref",
+				Annotations: map[string]string{
+					string(IngressClass): Istio,
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{
+					{
+						Host: host,
+					},
+				},
+			},
+		}
+		if len(paths) > 0 {
+			ingress.Spec.Rules[0].HTTP = &networkingv1.HTTPIngressRuleValue{}
+		}
+		for _, path := range paths {
+			ingress.Spec.Rules[0].HTTP.Paths = append(ingress.Spec.Rules[0].HTTP.Paths,
+				networkingv1.HTTPIngressPath{
+					Path:     path,
+					PathType: &pathType,
+				})
+		}
+		return ingress
+	}
+
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newPathIngress("test-web-ref", "shared.company.com", prefix, "/web"))
+	helper.indexer.Add(newPathIngress("test-exact-ref", "shared.company.com", exact, "/health"))
+	helper.indexer.Add(newPathIngress("test-host-ref", "whole.company.com", prefix))
+	helper.SetClaimPolicy(Istio, ClaimPolicy{Wildcard: WildcardExclusive, Granularity: GranularityPath})
+
+	tests := []struct {
+		name     string
+		input    *networkingv1.Ingress
+		expected error
+	}{
+		{
+			"should pass for a non overlapping prefix on a shared host",
+			newPathIngress("test-ingress", "shared.company.com", prefix, "/api", "/healthz"),
+			nil,
+		},
+		{
+			"should pass for a non overlapping exact path on a shared host",
+			newPathIngress("test-ingress", "shared.company.com", exact, "/health/live"),
+			nil,
+		},
+		{
+			"should fail for a prefix beneath a claimed prefix",
+			newPathIngress("test-ingress", "shared.company.com", prefix, "/api", "/web/static"),
+			errors.New("Domain shared.company.com already exists on path /web/static. Ingress test-web-ref in " +
+				"namespace test-ns-ref owns this path."),
+		},
+		{
+			"should fail for a prefix covering a claimed exact path",
+			newPathIngress("test-ingress", "shared.company.com", prefix, "/health"),
+			errors.New("Domain shared.company.com already exists on path /health. Ingress test-exact-ref in " +
+				"namespace test-ns-ref owns this path."),
+		},
+		{
+			"should fail for any path on a host claimed as a whole",
+			newPathIngress("test-ingress", "whole.company.com", exact, "/api"),
+			errors.New("Domain whole.company.com already exists on path /api. Ingress test-host-ref in " +
+				"namespace test-ns-ref owns this path."),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := helper.validateDomainClaims(context.TODO(), test.input, helper.GetProvider(test.input).GetDomains(test.input))
+			if test.expected == nil {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected.Error(), err.Error(), test.name)
+			}
+		})
+	}
+	helper.SetClaimPolicy(Istio, defaultClaimPolicy)
+}
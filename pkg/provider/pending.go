@@ -0,0 +1,149 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"sync"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultPendingClaimTTL is the default time an admitted ingress reserves its domains until the informer
+	// observes it
+	DefaultPendingClaimTTL = 30 * time.Second
+)
+
+// pendingClaim is an admitted ingress not observed by the informer yet
+type pendingClaim struct {
+	ingress *networkingv1.Ingress
+	expires time.Time
+}
+
+// pendingClaims holds the admitted ingresses by namespace/name until the informer observes them or their TTL
+// expires, so that the claims admitted concurrently see each other before they reach the cache
+type pendingClaims struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	claims map[string]pendingClaim
+}
+
+// newPendingClaims returns an empty pending claims table holding the claims for the given TTL
+func newPendingClaims(ttl time.Duration) *pendingClaims {
+	return &pendingClaims{
+		ttl:    ttl,
+		claims: map[string]pendingClaim{},
+	}
+}
+
+// add reserves the domains of the admitted ingress for the TTL, it returns the func restoring the previous
+// reservation of the ingress for an admission failing afterwards
+func (p *pendingClaims) add(ingress *networkingv1.Ingress) func() {
+	if p.ttl <= 0 {
+		return func() {}
+	}
+	key := ingress.Namespace + "/" + ingress.Name
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous, existed := p.claims[key]
+	p.claims[key] = pendingClaim{
+		ingress: ingress.DeepCopy(),
+		expires: time.Now().Add(p.ttl),
+	}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if existed {
+			p.claims[key] = previous
+		} else {
+			delete(p.claims, key)
+		}
+	}
+}
+
+// remove releases the claims of the ingress with the given namespace/name key
+func (p *pendingClaims) remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.claims, key)
+}
+
+// list returns the pending ingresses by namespace/name key, dropping the expired ones
+func (p *pendingClaims) list() map[string]*networkingv1.Ingress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	ingresses := map[string]*networkingv1.Ingress{}
+	for key, claim := range p.claims {
+		if now.After(claim.expires) {
+			delete(p.claims, key)
+			continue
+		}
+		ingresses[key] = claim.ingress
+	}
+	return ingresses
+}
+
+// SetPendingClaimTTL sets the time an admitted ingress reserves its domains until the informer observes it,
+// 0 to disable the reservations
+func (h *Helper) SetPendingClaimTTL(ttl time.Duration) {
+	h.pending = newPendingClaims(ttl)
+}
+
+// ObserveIngress releases the pending claims of an ingress added, updated or deleted in the informer cache,
+// to be set on the ResourceEventHandler of the informer
+func (h *Helper) ObserveIngress(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	h.pending.remove(key)
+}
+
+// indexesDomain checks if the domains of the ingress indexed by the index func of the provider with the name
+// 'index' include the domain
+func (h *Helper) indexesDomain(index string, ingress *networkingv1.Ingress, domain string) bool {
+	indexFunc := h.getIndexFunc(index)
+	if indexFunc == nil {
+		return false
+	}
+	domains, err := indexFunc(ingress)
+	if err != nil {
+		return false
+	}
+	for _, indexed := range domains {
+		if indexed == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// listIndexedDomains returns the domains in the cache index with the name 'index' along with the domains of
+// the pending ingresses
+func (h *Helper) listIndexedDomains(index string) []string {
+	domains := h.indexer.ListIndexFuncValues(index)
+	indexFunc := h.getIndexFunc(index)
+	if indexFunc == nil {
+		return domains
+	}
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		seen[domain] = true
+	}
+	for _, ingress := range h.pending.list() {
+		pendingDomains, err := indexFunc(ingress)
+		if err != nil {
+			continue
+		}
+		for _, domain := range pendingDomains {
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+	return domains
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func setupPendingClaims(ttl time.Duration) cache.Indexer {
+	indexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		})
+	helper.SetIndexer(indexer)
+	helper.SetPendingClaimTTL(ttl)
+	return indexer
+}
+
+func TestPendingDomainClaims(t *testing.T) {
+	indexer := setupPendingClaims(time.Minute)
+	defer helper.SetPendingClaimTTL(0)
+
+	first := newIstioIngress("test-namespace", "first-ingress", "test.company.com")
+	second := newIstioIngress("test-namespace", "second-ingress", "test.company.com")
+	validate := func(ingress *networkingv1.Ingress) error {
+		return helper.validateDomainClaims(context.TODO(), ingress, helper.GetProvider(ingress).GetDomains(ingress))
+	}
+
+	assert.Nil(t, validate(first), "should admit an unclaimed domain")
+	err := validate(second)
+	if assert.NotNil(t, err, "should fail for a domain of a pending ingress") {
+		assert.Equal(t, "Domain test.company.com already exists. Ingress first-ingress in namespace "+
+			"test-namespace owns this domain.", err.Error())
+	}
+	err = validate(newIstioIngress("test-namespace", "wildcard-ingress", "*.company.com"))
+	assert.NotNil(t, err, "should fail for a wildcard covering the domain of a pending ingress")
+	assert.Nil(t, validate(first), "should admit the pending ingress again")
+
+	indexer.Add(first)
+	helper.ObserveIngress(first)
+	assert.NotNil(t, validate(second), "should fail for a domain of an observed ingress")
+	indexer.Delete(first)
+	assert.Nil(t, validate(second), "should admit a domain once its pending ingress is observed and deleted")
+}
+
+func TestPendingDomainClaimsDryRun(t *testing.T) {
+	setupPendingClaims(time.Minute)
+	defer helper.SetPendingClaimTTL(0)
+
+	first := newIstioIngress("test-namespace", "first-ingress", "test.company.com")
+	second := newIstioIngress("test-namespace", "second-ingress", "test.company.com")
+	assert.Nil(t, helper.ValidateDryRunDomainClaims(context.TODO(), helper.GetProvider(first), first))
+	assert.Empty(t, helper.pending.list(), "should not reserve the domains of a dry run")
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), second, []string{"test.company.com"}),
+		"should admit a domain checked by a dry run only")
+}
+
+func TestPendingDomainClaimsUpdate(t *testing.T) {
+	indexer := setupPendingClaims(time.Minute)
+	defer helper.SetPendingClaimTTL(0)
+
+	first := newIstioIngress("test-namespace", "first-ingress", "test.company.com")
+	indexer.Add(first)
+	moved := newIstioIngress("test-namespace", "first-ingress", "other.company.com")
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), moved, []string{"other.company.com"}))
+
+	second := newIstioIngress("test-namespace", "second-ingress", "test.company.com")
+	assert.NotNil(t, helper.validateDomainClaims(context.TODO(), second, []string{"test.company.com"}),
+		"should fail for a domain dropped by a pending update until the informer observes it")
+	other := newIstioIngress("test-namespace", "other-ingress", "other.company.com")
+	assert.NotNil(t, helper.validateDomainClaims(context.TODO(), other, []string{"other.company.com"}),
+		"should fail for a domain added by a pending update")
+
+	indexer.Update(moved)
+	helper.ObserveIngress(moved)
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), second, []string{"test.company.com"}),
+		"should admit the domain dropped by an observed update")
+}
+
+func TestPendingDomainClaimsExpire(t *testing.T) {
+	setupPendingClaims(10 * time.Millisecond)
+	defer helper.SetPendingClaimTTL(0)
+
+	first := newIstioIngress("test-namespace", "first-ingress", "test.company.com")
+	second := newIstioIngress("test-namespace", "second-ingress", "test.company.com")
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), first, []string{"test.company.com"}))
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), second, []string{"test.company.com"}),
+		"should admit a domain once the pending claim expired")
+}
+
+func TestPendingDomainClaimsConcurrent(t *testing.T) {
+	setupPendingClaims(time.Minute)
+	defer helper.SetPendingClaimTTL(0)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if helper.validateDomainClaims(context.TODO(), newIstioIngress("test-namespace", name, "test.company.com"),
+				[]string{"test.company.com"}) == nil {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, admitted, "should admit a single one of the concurrent claims")
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WildcardPolicy defines how wildcard domain claims interact with the concrete domains beneath them
+type WildcardPolicy string
+
+const (
+	// WildcardExclusive rejects a wildcard claim covering a domain owned by another ingress and vice versa
+	WildcardExclusive WildcardPolicy = "exclusive"
+
+	// WildcardSpecific rejects a wildcard claim covering a domain owned by another ingress, but allows to claim
+	// a domain beneath the wildcard of another ingress as the more specific claim wins
+	WildcardSpecific WildcardPolicy = "specific"
+
+	// WildcardExact treats wildcard domains as plain domains, only exact matches conflict
+	WildcardExact WildcardPolicy = "exact"
+)
+
+// ClaimGranularity defines the unit of a domain claim
+type ClaimGranularity string
+
+const (
+	// GranularityHost makes an ingress claim the whole host, any other ingress on the host conflicts
+	GranularityHost ClaimGranularity = "host"
+
+	// GranularityPath makes an ingress claim the paths it routes on the host, only the ingresses routing an
+	// overlapping path conflict
+	GranularityPath ClaimGranularity = "path"
+)
+
+// ClaimOwner defines who owns the domains claimed by an ingress
+type ClaimOwner string
+
+const (
+	// OwnerIngress makes every ingress the owner of its claims, no other ingress may claim the same domains
+	OwnerIngress ClaimOwner = "ingress"
+
+	// OwnerNamespace makes the namespace the owner of the claims, the ingresses of a namespace share its domains
+	OwnerNamespace ClaimOwner = "namespace"
+
+	// OwnerLabel makes the value of a namespace label, such as a team, the owner of the claims. The ingresses
+	// of all the namespaces with the same label value share their domains.
+	OwnerLabel ClaimOwner = "label"
+)
+
+// ClaimPolicy holds the domain claim semantics enforced for the ingresses of a provider
+type ClaimPolicy struct {
+	Wildcard    WildcardPolicy
+	Granularity ClaimGranularity
+	Owner       ClaimOwner
+
+	// OwnerLabel is the namespace label key identifying the owner with the OwnerLabel owner
+	OwnerLabel string
+}
+
+var (
+	// defaultClaimPolicy is applied to the providers without an explicit claim policy
+	defaultClaimPolicy = ClaimPolicy{
+		Wildcard:    WildcardExclusive,
+		Granularity: GranularityHost,
+		Owner:       OwnerIngress,
+	}
+)
+
+// ParseWildcardPolicy returns the wildcard policy with the given name
+func ParseWildcardPolicy(name string) (WildcardPolicy, error) {
+	switch policy := WildcardPolicy(name); policy {
+	case WildcardExclusive, WildcardSpecific, WildcardExact:
+		return policy, nil
+	}
+	return "", fmt.Errorf("Unknown wildcard policy: %s", name)
+}
+
+// ParseClaimGranularity returns the claim granularity with the given name
+func ParseClaimGranularity(name string) (ClaimGranularity, error) {
+	switch granularity := ClaimGranularity(name); granularity {
+	case GranularityHost, GranularityPath:
+		return granularity, nil
+	}
+	return "", fmt.Errorf("Unknown claim granularity: %s", name)
+}
+
+// ParseClaimOwner returns the claim owner with the given name along with the label key for the label owner,
+// which is given as "label:<key>"
+func ParseClaimOwner(name string) (ClaimOwner, string, error) {
+	switch owner := ClaimOwner(name); owner {
+	case OwnerIngress, OwnerNamespace:
+		return owner, "", nil
+	}
+	if strings.HasPrefix(name, string(OwnerLabel)+":") {
+		if label := strings.TrimPrefix(name, string(OwnerLabel)+":"); label != "" {
+			return OwnerLabel, label, nil
+		}
+	}
+	return "", "", fmt.Errorf("Unknown claim owner: %s", name)
+}
+
+// SetClaimPolicy sets the claim policy enforced for the ingresses of the provider with the given name
+func (h *Helper) SetClaimPolicy(name string, policy ClaimPolicy) {
+	h.policies[name] = policy
+}
+
+// GetClaimPolicy returns the claim policy enforced for the ingresses of the provider with the given name
+func (h *Helper) GetClaimPolicy(name string) ClaimPolicy {
+	if policy, exists := h.policies[name]; exists {
+		return policy
+	}
+	return defaultClaimPolicy
+}
+
+// SetNamespaceStore allows to set the Namespace cache store used to resolve the namespace labels of the
+// OwnerLabel claim owner
+func (h *Helper) SetNamespaceStore(store cache.Store) {
+	h.namespaces = store
+}
+
+// getNamespaceLabel returns the value of the label on the namespace with the given name, "" if either the
+// namespace or the label is not known
+func (h *Helper) getNamespaceLabel(name string, label string) string {
+	if h.namespaces == nil {
+		return ""
+	}
+	obj, exists, err := h.namespaces.GetByKey(name)
+	if err != nil || !exists {
+		return ""
+	}
+	if namespace, ok := obj.(*v1.Namespace); ok {
+		return namespace.Labels[label]
+	}
+	return ""
+}
+
+// getClaimOwner returns the identity owning the claims of the ingress according to the claim policy
+func (h *Helper) getClaimOwner(policy ClaimPolicy, ingress *networkingv1.Ingress) string {
+	switch policy.Owner {
+	case OwnerNamespace:
+		return "namespace:" + ingress.Namespace
+	case OwnerLabel:
+		if value := h.getNamespaceLabel(ingress.Namespace, policy.OwnerLabel); value != "" {
+			return "label:" + value
+		}
+		return "namespace:" + ingress.Namespace
+	}
+	return "ingress:" + ingress.Namespace + "/" + ingress.Name
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseWildcardPolicy(t *testing.T) {
+	for _, name := range []string{"exclusive", "specific", "exact"} {
+		policy, err := ParseWildcardPolicy(name)
+		assert.Nil(t, err, "should parse "+name)
+		assert.Equal(t, WildcardPolicy(name), policy)
+	}
+
+	_, err := ParseWildcardPolicy("other")
+	if assert.NotNil(t, err, "should fail for an unknown policy") {
+		assert.Equal(t, "Unknown wildcard policy: other", err.Error())
+	}
+}
+
+func TestParseClaimGranularity(t *testing.T) {
+	for _, name := range []string{"host", "path"} {
+		granularity, err := ParseClaimGranularity(name)
+		assert.Nil(t, err, "should parse "+name)
+		assert.Equal(t, ClaimGranularity(name), granularity)
+	}
+
+	_, err := ParseClaimGranularity("other")
+	if assert.NotNil(t, err, "should fail for an unknown granularity") {
+		assert.Equal(t, "Unknown claim granularity: other", err.Error())
+	}
+}
+
+func TestParseClaimOwner(t *testing.T) {
+	tests := []struct {
+		input string
+		owner ClaimOwner
+		label string
+		err   bool
+	}{
+		{"ingress", OwnerIngress, "", false},
+		{"namespace", OwnerNamespace, "", false},
+		{"label:team", OwnerLabel, "team", false},
+		{"label:", "", "", true},
+		{"label", "", "", true},
+		{"other", "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			owner, label, err := ParseClaimOwner(test.input)
+			if test.err {
+				assert.NotNil(t, err, test.input)
+			} else {
+				assert.Nil(t, err, test.input)
+			}
+			assert.Equal(t, test.owner, owner, test.input)
+			assert.Equal(t, test.label, label, test.input)
+		})
+	}
+}
+
+func TestValidateOwnedDomainClaims(t *testing.T) {
+	newATSIngress := func(namespace string, name string, domain string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Annotations: map[string]string{
+					string(DefaultDomain): domain,
+					string(Ports):         "80",
+				},
+			},
+		}
+	}
+	newNamespace := func(name string, team string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: v1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"team": team,
+				},
+			},
+		}
+	}
+
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			ATS: helper.GetProviderByName(ATS).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newATSIngress("test-ns-ref", "test-ingress-ref", "test-owned.company.com"))
+
+	namespaces := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	namespaces.Add(newNamespace("test-ns-ref", "team-a"))
+	namespaces.Add(newNamespace("test-ns-same-team", "team-a"))
+	namespaces.Add(newNamespace("test-ns-other-team", "team-b"))
+	helper.SetNamespaceStore(namespaces)
+
+	tests := []struct {
+		name     string
+		policy   ClaimPolicy
+		input    *networkingv1.Ingress
+		expected error
+	}{
+		{
+			"should fail for another ingress in the same namespace with the ingress owner",
+			ClaimPolicy{Owner: OwnerIngress},
+			newATSIngress("test-ns-ref", "test-ingress", "test-owned.company.com"),
+			errors.New("Domain test-owned.company.com already exists. Ingress test-ingress-ref in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+		{
+			"should pass for another ingress in the same namespace with the namespace owner",
+			ClaimPolicy{Owner: OwnerNamespace},
+			newATSIngress("test-ns-ref", "test-ingress", "test-owned.company.com"),
+			nil,
+		},
+		{
+			"should fail for an ingress in another namespace with the namespace owner",
+			ClaimPolicy{Owner: OwnerNamespace},
+			newATSIngress("test-ns-same-team", "test-ingress", "test-owned.company.com"),
+			errors.New("Domain test-owned.company.com already exists. Ingress test-ingress-ref in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+		{
+			"should pass for an ingress in a namespace with the same label with the label owner",
+			ClaimPolicy{Owner: OwnerLabel, OwnerLabel: "team"},
+			newATSIngress("test-ns-same-team", "test-ingress", "test-owned.company.com"),
+			nil,
+		},
+		{
+			"should fail for an ingress in a namespace with another label with the label owner",
+			ClaimPolicy{Owner: OwnerLabel, OwnerLabel: "team"},
+			newATSIngress("test-ns-other-team", "test-ingress", "test-owned.company.com"),
+			errors.New("Domain test-owned.company.com already exists. Ingress test-ingress-ref in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+		{
+			"should fail for an ingress in an unknown namespace with the label owner",
+			ClaimPolicy{Owner: OwnerLabel, OwnerLabel: "team"},
+			newATSIngress("test-ns-unknown", "test-ingress", "test-owned.company.com"),
+			errors.New("Domain test-owned.company.com already exists. Ingress test-ingress-ref in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helper.SetClaimPolicy(ATS, test.policy)
+			err := helper.validateDomainClaims(context.TODO(), test.input, helper.GetProvider(test.input).GetDomains(test.input))
+			if test.expected == nil {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected.Error(), err.Error(), test.name)
+			}
+		})
+	}
+	helper.SetClaimPolicy(ATS, defaultClaimPolicy)
+	helper.SetNamespaceStore(nil)
+}
+
+func TestGetClaimPolicy(t *testing.T) {
+	assert.Equal(t, defaultClaimPolicy, helper.GetClaimPolicy("undefined"), "should return the default policy")
+
+	helper.SetClaimPolicy(ATS, ClaimPolicy{Wildcard: WildcardExact})
+	assert.Equal(t, ClaimPolicy{Wildcard: WildcardExact}, helper.GetClaimPolicy(ATS), "should return the set policy")
+	helper.SetClaimPolicy(ATS, defaultClaimPolicy)
+}
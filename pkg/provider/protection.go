@@ -0,0 +1,113 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// Protected is the annotation on ingress resources protecting their domains from being dropped by an update
+	// and the ingress from being deleted when set to "true"
+	Protected Annotation = "ingressclaim.yahoo.io/protected"
+)
+
+// SetProtectedHosts sets the hosts, or wildcards covering them, that no ingress may drop or be deleted with
+func (h *Helper) SetProtectedHosts(hosts []string) {
+	h.protected = h.appendNonEmpty([]string{}, hosts...)
+}
+
+// ValidateRemoval checks that none of the domains dropped by the update of oldIngress into ingress, or by the
+// deletion of oldIngress when ingress is nil, is protected by the Protected annotation of oldIngress, by the
+// protected hosts or by a protected DomainClaim. The domains the old ingress releases to the cached ingresses
+// claiming them may be dropped to complete their handoff. All the protected domains are reported in a single error.
+func (h *Helper) ValidateRemoval(oldIngress *networkingv1.Ingress, ingress *networkingv1.Ingress) error {
+	remaining := map[string]bool{}
+	if ingress != nil {
+		for _, domain := range h.GetProvider(ingress).GetDomains(ingress) {
+			remaining[domain] = true
+		}
+	}
+	rejections := []*RejectionError{}
+	for _, domain := range h.GetProvider(oldIngress).GetDomains(oldIngress) {
+		if remaining[domain] || h.isReleasedToCached(oldIngress, domain) {
+			continue
+		}
+		rejection, err := h.protectionError(oldIngress, ingress == nil, domain)
+		if err != nil {
+			return err
+		}
+		if rejection != nil {
+			rejections = append(rejections, rejection)
+		}
+	}
+	if len(rejections) > 0 {
+		return newRejectionErrors(rejections)
+	}
+	return nil
+}
+
+// protectionError returns the rejection error of the protected domain dropped by the ingress, or deleted along
+// with it, nil if the domain is not protected
+func (h *Helper) protectionError(ingress *networkingv1.Ingress, deleted bool, domain string) (*RejectionError,
+	error) {
+	action := "dropped from"
+	if deleted {
+		action = "deleted with"
+	}
+	rejection := &RejectionError{
+		Reason: ReasonProtectedHost,
+		Field:  h.domainField(ingress, domain),
+		Host:   domain,
+	}
+
+	if ingress.Annotations[string(Protected)] == "true" {
+		rejection.Message = fmt.Sprintf("Domain %s cannot be %s Ingress %s in namespace %s, the ingress is "+
+			"protected by the %s annotation.", domain, action, ingress.Name, ingress.Namespace, Protected)
+		return rejection, nil
+	}
+	for _, protected := range h.protected {
+		if protected == domain || protected == h.wildcardOf(domain) {
+			rejection.Message = fmt.Sprintf("Domain %s cannot be %s Ingress %s in namespace %s, the domain is "+
+				"protected.", domain, action, ingress.Name, ingress.Namespace)
+			return rejection, nil
+		}
+	}
+	if h.reservations == nil {
+		return nil, nil
+	}
+	claims, err := h.lookupDomainClaims(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range claims {
+		if claim.Spec.Protected {
+			rejection.Message = fmt.Sprintf("Domain %s cannot be %s Ingress %s in namespace %s, the domain is "+
+				"protected by DomainClaim %s.", domain, action, ingress.Name, ingress.Namespace, claim.Name)
+			rejection.Owner = claim.Name
+			return rejection, nil
+		}
+	}
+	return nil, nil
+}
+
+// isReleasedToCached checks if the ingress releases the domain through the ReleaseTo annotation to another
+// ingress of the cache claiming it, so that an ingress cannot drop a protected domain by releasing it to an
+// ingress which does not take it over
+func (h *Helper) isReleasedToCached(ingress *networkingv1.Ingress, domain string) bool {
+	cached, err := h.lookupIngressesByDomain(h.getClaimIndex(h.GetProvider(ingress).Name()), domain)
+	if err != nil {
+		return false
+	}
+	for _, cachedIngress := range cached {
+		if cachedIngress.Namespace == ingress.Namespace && cachedIngress.Name == ingress.Name {
+			continue
+		}
+		if h.releasesDomain(ingress, domain, cachedIngress) {
+			return true
+		}
+	}
+	return false
+}
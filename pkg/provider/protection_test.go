@@ -0,0 +1,127 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// withAnnotations returns an Istio ingress of the hosts with the given annotations
+func withAnnotations(annotations map[string]string, hosts ...string) *networkingv1.Ingress {
+	ingress := newIstioIngress("test-namespace", "test-ingress", hosts...)
+	for key, value := range annotations {
+		ingress.Annotations[key] = value
+	}
+	return ingress
+}
+
+func TestValidateRemoval(t *testing.T) {
+	claims := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{DomainClaimIndex: DomainClaimsIndexFunc})
+	claims.Add(&DomainClaim{
+		ObjectMeta: v1.ObjectMeta{Name: "payments"},
+		Spec: DomainClaimSpec{
+			Hosts:      []string{"pay.company.com"},
+			Namespaces: []string{"test-namespace"},
+			Protected:  true,
+		},
+	})
+	claims.Add(&DomainClaim{
+		ObjectMeta: v1.ObjectMeta{Name: "docs"},
+		Spec: DomainClaimSpec{
+			Hosts:      []string{"docs.company.com"},
+			Namespaces: []string{"test-namespace"},
+		},
+	})
+	helper.SetDomainClaimIndexer(claims)
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newIstioIngress("team-a", "test-ingress", "www.company.com"))
+	helper.SetProtectedHosts([]string{"www.company.com", "*.prod.company.com", " "})
+	defer helper.SetDomainClaimIndexer(nil)
+	defer helper.SetProtectedHosts(nil)
+
+	protected := map[string]string{string(Protected): "true"}
+	tests := []struct {
+		name       string
+		oldIngress *networkingv1.Ingress
+		ingress    *networkingv1.Ingress
+		expected   string
+	}{
+		{
+			"should pass for an update dropping an unprotected domain",
+			newIstioIngress("test-namespace", "test-ingress", "app.company.com", "docs.company.com"),
+			newIstioIngress("test-namespace", "test-ingress"),
+			"",
+		},
+		{
+			"should pass for an update keeping the protected domains",
+			newIstioIngress("test-namespace", "test-ingress", "www.company.com", "app.company.com"),
+			newIstioIngress("test-namespace", "test-ingress", "www.company.com"),
+			"",
+		},
+		{
+			"should fail for an update dropping a domain of a protected ingress",
+			withAnnotations(protected, "app.company.com", "api.company.com"),
+			withAnnotations(protected, "api.company.com"),
+			"Domain app.company.com cannot be dropped from Ingress test-ingress in namespace test-namespace, " +
+				"the ingress is protected by the ingressclaim.yahoo.io/protected annotation.",
+		},
+		{
+			"should fail for an update dropping protected hosts",
+			newIstioIngress("test-namespace", "test-ingress", "www.company.com", "api.prod.company.com",
+				"app.company.com"),
+			newIstioIngress("test-namespace", "test-ingress", "app.company.com"),
+			"Domain www.company.com cannot be dropped from Ingress test-ingress in namespace test-namespace, " +
+				"the domain is protected. Domain api.prod.company.com cannot be dropped from Ingress " +
+				"test-ingress in namespace test-namespace, the domain is protected.",
+		},
+		{
+			"should fail for a deletion removing a domain of a protected DomainClaim",
+			newIstioIngress("test-namespace", "test-ingress", "pay.company.com"),
+			nil,
+			"Domain pay.company.com cannot be deleted with Ingress test-ingress in namespace test-namespace, " +
+				"the domain is protected by DomainClaim payments.",
+		},
+		{
+			"should pass for a deletion of an unprotected ingress",
+			newIstioIngress("test-namespace", "test-ingress", "app.company.com"),
+			nil,
+			"",
+		},
+		{
+			"should pass for an update dropping a protected domain released to a cached ingress claiming it",
+			withAnnotations(map[string]string{string(ReleaseTo): "www.company.com=team-a"}, "www.company.com"),
+			newIstioIngress("test-namespace", "test-ingress"),
+			"",
+		},
+		{
+			"should fail for an update dropping a protected domain released to no cached ingress claiming it",
+			withAnnotations(map[string]string{string(ReleaseTo): "www.company.com=team-b/test-ingress"},
+				"www.company.com"),
+			newIstioIngress("test-namespace", "test-ingress"),
+			"Domain www.company.com cannot be dropped from Ingress test-ingress in namespace test-namespace, " +
+				"the domain is protected.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := helper.ValidateRemoval(test.oldIngress, test.ingress)
+			if test.expected == "" {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected, err.Error(), test.name)
+				for _, rejection := range Rejections(err) {
+					assert.Equal(t, ReasonProtectedHost, rejection.Reason, test.name)
+				}
+			}
+		})
+	}
+}
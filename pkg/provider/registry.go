@@ -0,0 +1,152 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Register makes the provider available by its name along with the IngressClass controller name it serves,
+// "" if it has none. The registered providers are enabled in registration order until SetProviders selects
+// them, so Register is expected to be called from the init func of the package implementing the provider.
+// Register panics when a provider with the same name is already registered.
+func Register(provider Provider, controller string) {
+	name := provider.Name()
+	if name == "" {
+		panic("provider: Register called with an unnamed provider")
+	}
+	if _, exists := helper.providers[name]; exists {
+		panic("provider: Register called twice for provider " + name)
+	}
+	helper.providers[name] = provider
+	helper.registered = append(helper.registered, name)
+	helper.enabled = append(helper.enabled, name)
+	if controller != "" {
+		helper.controllers[controller] = name
+	}
+}
+
+// SetProviders enables the registered providers with the given names, in the order they are matched by
+// GetProvider. All the registered providers are enabled in registration order when names is empty.
+func (h *Helper) SetProviders(names []string) error {
+	if len(names) == 0 {
+		h.enabled = append([]string{}, h.registered...)
+		return nil
+	}
+	enabled := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if _, exists := h.providers[name]; !exists {
+			return fmt.Errorf("Unknown provider: %s", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			enabled = append(enabled, name)
+		}
+	}
+	h.enabled = enabled
+	return nil
+}
+
+// SetDefaultProvider sets the provider serving the ingresses without a class when the cluster has no default
+// IngressClass, one of the enabled providers or "none" to skip their checks. The first enabled provider is the
+// default when name is empty.
+func (h *Helper) SetDefaultProvider(name string) error {
+	if name != "" && name != None && !h.isEnabled(name) {
+		return fmt.Errorf("Default provider %s is not enabled", name)
+	}
+	h.defaultName = name
+	return nil
+}
+
+// CheckProviderOverlaps checks that no two enabled providers serve the same ingresses, which would leave their
+// resolution to the order of precedence. The providers are probed with an ingress of every known class name,
+// given through the class annotation and spec.ingressClassName, along with an ingress without a class.
+func (h *Helper) CheckProviderOverlaps() error {
+	classNames := map[string]bool{"": true}
+	for _, name := range h.enabled {
+		classNames[name] = true
+	}
+	for className := range h.classNames {
+		classNames[className] = true
+	}
+	if h.classes != nil {
+		for _, obj := range h.classes.List() {
+			if class, ok := obj.(*networkingv1.IngressClass); ok {
+				classNames[class.Name] = true
+			}
+		}
+	}
+	sorted := []string{}
+	for className := range classNames {
+		sorted = append(sorted, className)
+	}
+	sort.Strings(sorted)
+
+	for _, className := range sorted {
+		for _, probe := range h.classProbes(className) {
+			serving := []string{}
+			for _, name := range h.enabled {
+				if h.providers[name].ServesIngress(probe) {
+					serving = append(serving, name)
+				}
+			}
+			if len(serving) > 1 {
+				return fmt.Errorf("Providers %s all serve the ingresses of class %q", strings.Join(serving, ", "),
+					className)
+			}
+		}
+	}
+	return nil
+}
+
+// classProbes returns the ingresses probing the providers serving the class name, one with the class
+// annotation and one with spec.ingressClassName, a single ingress without a class for an empty class name
+func (h *Helper) classProbes(className string) [](*networkingv1.Ingress) {
+	meta := v1.ObjectMeta{
+		Name:        "ingress-claim-probe",
+		Namespace:   v1.NamespaceDefault,
+		Annotations: map[string]string{},
+	}
+	if className == "" {
+		return [](*networkingv1.Ingress){{ObjectMeta: meta}}
+	}
+	annotated := &networkingv1.Ingress{ObjectMeta: *meta.DeepCopy()}
+	annotated.Annotations[string(IngressClass)] = className
+	named := &networkingv1.Ingress{ObjectMeta: meta}
+	named.Spec.IngressClassName = &className
+	return [](*networkingv1.Ingress){annotated, named}
+}
+
+// GetProviders returns the enabled provider instances in the order they are matched by GetProvider
+func (h *Helper) GetProviders() []Provider {
+	providers := []Provider{}
+	for _, name := range h.enabled {
+		providers = append(providers, h.providers[name])
+	}
+	return providers
+}
+
+// SetClassNames sets the mapping of ingress class names to provider names, for the classes without an
+// IngressClass resource. The class names missing from the mapping are expected to be the provider names.
+func (h *Helper) SetClassNames(classNames map[string]string) {
+	h.classNames = classNames
+}
+
+// ResolveProviderName returns the name of the provider responsible for the given ingress, for the providers
+// to check if they serve an ingress
+func (h *Helper) ResolveProviderName(ingress *networkingv1.Ingress) string {
+	return h.resolveProviderName(ingress)
+}
+
+// ValidateDomainClaims performs the duplicate domain check of the ingress on the domains, for the providers
+// to check the domains they extract from an ingress against the claims of the other ingresses
+func (h *Helper) ValidateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress, domains []string) error {
+	return h.validateDomainClaims(ctx, ingress, domains)
+}
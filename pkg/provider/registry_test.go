@@ -0,0 +1,154 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// testProvider is an in-house provider serving the ingresses of its class, claiming their rule hosts
+type testProvider struct {
+	istio
+}
+
+func (p *testProvider) Name() string {
+	return "internal"
+}
+
+func (p *testProvider) ServesIngress(ingress *networkingv1.Ingress) bool {
+	return helper.ResolveProviderName(ingress) == p.Name()
+}
+
+// withRegistry runs the test on a helper with the provider registrations restored afterwards
+func withRegistry(t *testing.T, test func(t *testing.T)) {
+	providers, registered, enabled := map[string]Provider{}, helper.registered, helper.enabled
+	for name, provider := range helper.providers {
+		providers[name] = provider
+	}
+	controllers := map[string]string{}
+	for controller, name := range helper.controllers {
+		controllers[controller] = name
+	}
+	defer func() {
+		helper.providers, helper.registered, helper.enabled = providers, registered, enabled
+		helper.controllers = controllers
+		helper.SetClassNames(nil)
+		helper.SetDefaultProvider("")
+		helper.SetIngressClassStore(nil)
+	}()
+	test(t)
+}
+
+func TestRegister(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		Register(&testProvider{}, "example.com/ingress-controller")
+
+		assert.Equal(t, []string{ATS, Istio, "internal"}, helper.enabled,
+			"should enable the registered providers in registration order")
+		assert.Equal(t, "internal", helper.controllers["example.com/ingress-controller"],
+			"should map the registered controller to the provider")
+		assert.Equal(t, "internal", helper.GetProvider(newClassedIngress("", "internal")).Name(),
+			"should serve the ingresses of the registered provider class")
+		assert.Panics(t, func() {
+			Register(&testProvider{}, "")
+		}, "should panic when registering a provider twice")
+	})
+}
+
+func TestSetProviders(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		assert.NotNil(t, helper.SetProviders([]string{ATS, "unknown"}), "should fail for an unknown provider")
+
+		assert.Nil(t, helper.SetProviders([]string{Istio, ATS, Istio}), "err should be nil")
+		assert.Equal(t, []string{Istio, ATS}, helper.enabled, "should enable the providers once in order")
+		assert.Equal(t, Istio, helper.GetDefaultProvider().Name(),
+			"should serve the ingresses without class by the first provider")
+
+		assert.Nil(t, helper.SetProviders([]string{ATS}), "err should be nil")
+		assert.Len(t, helper.GetProviders(), 1, "should only return the enabled providers")
+		assert.Equal(t, None, helper.GetProvider(newClassedIngress(Istio, "")).Name(),
+			"should serve the ingresses of a disabled provider by no provider")
+		assert.Empty(t, helper.GetProvider(newClassedIngress(Istio, "")).GetDomains(newClassedIngress(Istio, "")),
+			"should not claim the domains of the ingresses of a disabled provider")
+
+		assert.Nil(t, helper.SetProviders(nil), "err should be nil")
+		assert.Equal(t, []string{ATS, Istio}, helper.enabled, "should enable all the registered providers")
+	})
+}
+
+func TestSetClassNames(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		helper.SetClassNames(map[string]string{"ats-internal": ATS, "mesh": Istio})
+
+		assert.Equal(t, Istio, helper.ResolveProviderName(newClassedIngress("mesh", "")),
+			"should resolve the class annotation through the class names")
+		assert.Equal(t, ATS, helper.ResolveProviderName(newClassedIngress("", "ats-internal")),
+			"should resolve spec.ingressClassName through the class names")
+		assert.Equal(t, Istio, helper.ResolveProviderName(newClassedIngress(Istio, "")),
+			"should resolve an unmapped class name as the provider name")
+	})
+}
+
+func TestSetDefaultProvider(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		assert.NotNil(t, helper.SetDefaultProvider("unknown"), "should fail for a provider not enabled")
+
+		assert.Nil(t, helper.SetDefaultProvider(Istio), "err should be nil")
+		assert.Equal(t, Istio, helper.GetProvider(newClassedIngress("", "")).Name(),
+			"should serve the ingresses without class by the default provider")
+
+		assert.Nil(t, helper.SetDefaultProvider(None), "err should be nil")
+		ingress := newClassedIngress("", "")
+		ingress.Annotations[string(DefaultDomain)] = "app.company.com"
+		p := helper.GetProvider(ingress)
+		assert.Equal(t, None, p.Name(), "should serve the ingresses without class by no provider")
+		assert.Nil(t, p.ValidateSemantics(ingress), "should skip the validation checks")
+		assert.Empty(t, p.GetDomains(ingress), "should claim no domains")
+	})
+}
+
+func TestGetProviderPrecedence(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		Register(&overlappingProvider{}, "")
+
+		ingress := newClassedIngress(Istio, "")
+		assert.Nil(t, helper.SetProviders([]string{Istio, "overlapping"}), "err should be nil")
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, Istio, helper.GetProvider(ingress).Name(), "should resolve to the first provider")
+		}
+		assert.Nil(t, helper.SetProviders([]string{"overlapping", Istio}), "err should be nil")
+		assert.Equal(t, "overlapping", helper.GetProvider(ingress).Name(), "should follow the order of precedence")
+	})
+}
+
+func TestCheckProviderOverlaps(t *testing.T) {
+	withRegistry(t, func(t *testing.T) {
+		assert.Nil(t, helper.CheckProviderOverlaps(), "should not find overlaps between ATS and Istio")
+
+		store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+		store.Add(newIngressClass("public", IstioController, false))
+		helper.SetIngressClassStore(store)
+		Register(&overlappingProvider{}, "")
+
+		err := helper.CheckProviderOverlaps()
+		if assert.NotNil(t, err, "should detect providers serving the same class") {
+			assert.Contains(t, err.Error(), "Providers istio, overlapping all serve the ingresses of class")
+		}
+
+		assert.Nil(t, helper.SetProviders([]string{ATS, "overlapping"}), "err should be nil")
+		assert.Nil(t, helper.CheckProviderOverlaps(), "should only check the enabled providers")
+	})
+}
+
+// overlappingProvider serves the same ingresses as Istio
+type overlappingProvider struct {
+	istio
+}
+
+func (p *overlappingProvider) Name() string {
+	return "overlapping"
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	// ClaimStatus is the annotation on ingress resources reporting the domains they claim along with the
+	// conflicting claims, written back by the claim status reporter
+	ClaimStatus Annotation = "ingressclaim.yahoo.io/claim-status"
+)
+
+// DomainClaimStatus is the claim status of an ingress, as reported on its ClaimStatus annotation
+type DomainClaimStatus struct {
+	// Provider is the name of the provider serving the ingress
+	Provider string `json:"provider"`
+	// Hosts are the domains claimed by the ingress
+	Hosts []string `json:"hosts"`
+	// Conflicts are the claims of other owners conflicting with the claims of the ingress
+	Conflicts []ClaimConflictStatus `json:"conflicts,omitempty"`
+}
+
+// ClaimConflictStatus is a claim of another owner conflicting with the claim of an ingress on a host
+type ClaimConflictStatus struct {
+	Reason RejectionReason `json:"reason"`
+	Host   string          `json:"host"`
+	Path   string          `json:"path,omitempty"`
+	// Owner is the namespace/name of the conflicting ingress or the name of the reserving DomainClaim
+	Owner string `json:"owner"`
+	// FirstClaim is true when the ingress was created before the conflicting ingress, i.e. the webhook denied,
+	// or would deny, the conflicting ingress rather than this one
+	FirstClaim bool `json:"firstClaim,omitempty"`
+}
+
+// GetClaimStatus returns the claim status of the ingress: the domains claimed by its provider and the claims
+// of the cached ingresses and DomainClaims conflicting with them, such as the duplicates admitted before the
+// webhook was enforced. The claims are checked the same way as the admission checks, without leasing them.
+func (h *Helper) GetClaimStatus(ingress *networkingv1.Ingress) (*DomainClaimStatus, error) {
+	p := h.GetProvider(ingress)
+	status := &DomainClaimStatus{
+		Provider:  p.Name(),
+		Hosts:     []string{},
+		Conflicts: []ClaimConflictStatus{},
+	}
+	index := h.getClaimIndex(p.Name())
+	policy := h.GetClaimPolicy(p.Name())
+	seen := map[string]bool{}
+	for _, domain := range p.GetDomains(ingress) {
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		status.Hosts = append(status.Hosts, domain)
+
+		rejections, err := h.checkDomainClaim(index, policy, ingress, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, rejection := range rejections {
+			status.Conflicts = append(status.Conflicts, ClaimConflictStatus{
+				Reason:     rejection.Reason,
+				Host:       rejection.Host,
+				Path:       rejection.Path,
+				Owner:      rejection.Owner,
+				FirstClaim: rejection.Reason == ReasonDomainConflict && h.claimedBefore(ingress, rejection.Owner),
+			})
+		}
+	}
+	return status, nil
+}
+
+// claimedBefore checks if the ingress was created before the cached ingress with the namespace/name key,
+// the ingress with the lower key first when both were created at the same time
+func (h *Helper) claimedBefore(ingress *networkingv1.Ingress, key string) bool {
+	obj, exists, err := h.indexer.GetByKey(key)
+	if err != nil || !exists {
+		return false
+	}
+	owner, err := ToIngress(obj)
+	if err != nil {
+		return false
+	}
+	if !ingress.CreationTimestamp.Equal(&owner.CreationTimestamp) {
+		return ingress.CreationTimestamp.Before(&owner.CreationTimestamp)
+	}
+	return ingress.Namespace+"/"+ingress.Name < key
+}
+
+// IsClaimStatusUpdate checks if the update of oldIngress into ingress only changes the ClaimStatus annotation
+// or the metadata maintained by the apiserver, which leaves the claims of the ingress unchanged
+func (h *Helper) IsClaimStatusUpdate(oldIngress *networkingv1.Ingress, ingress *networkingv1.Ingress) bool {
+	if oldIngress.Annotations[string(ClaimStatus)] == ingress.Annotations[string(ClaimStatus)] {
+		return false
+	}
+	return apiequality.Semantic.DeepEqual(oldIngress.Spec, ingress.Spec) &&
+		apiequality.Semantic.DeepEqual(oldIngress.Labels, ingress.Labels) &&
+		apiequality.Semantic.DeepEqual(h.withoutClaimStatus(oldIngress.Annotations),
+			h.withoutClaimStatus(ingress.Annotations))
+}
+
+// withoutClaimStatus returns a copy of the annotations without the ClaimStatus annotation
+func (h *Helper) withoutClaimStatus(annotations map[string]string) map[string]string {
+	copied := map[string]string{}
+	for key, value := range annotations {
+		if key != string(ClaimStatus) {
+			copied[key] = value
+		}
+	}
+	return copied
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// withCreationTime returns the ingress created at the given time
+func withCreationTime(ingress *networkingv1.Ingress, created time.Time) *networkingv1.Ingress {
+	ingress.CreationTimestamp = v1.NewTime(created)
+	return ingress
+}
+
+func TestGetClaimStatus(t *testing.T) {
+	created := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	legacyIngress := withCreationTime(newIstioIngress("test-namespace", "legacy-ingress", "taken.company.com"), created)
+	duplicateIngress := withCreationTime(newIstioIngress("test-namespace", "duplicate-ingress", "taken.company.com",
+		"free.company.com", "taken.company.com"), created.Add(time.Hour))
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(legacyIngress)
+	helper.indexer.Add(duplicateIngress)
+
+	tests := []struct {
+		name     string
+		ingress  *networkingv1.Ingress
+		expected *DomainClaimStatus
+	}{
+		{
+			"should report the duplicate created after the ingress as a conflict of its first claim",
+			legacyIngress,
+			&DomainClaimStatus{
+				Provider: Istio,
+				Hosts:    []string{"taken.company.com"},
+				Conflicts: []ClaimConflictStatus{
+					{ReasonDomainConflict, "taken.company.com", "", "test-namespace/duplicate-ingress", true},
+				},
+			},
+		},
+		{
+			"should report the hosts once along with the conflict of the duplicate host",
+			duplicateIngress,
+			&DomainClaimStatus{
+				Provider: Istio,
+				Hosts:    []string{"taken.company.com", "free.company.com"},
+				Conflicts: []ClaimConflictStatus{
+					{ReasonDomainConflict, "taken.company.com", "", "test-namespace/legacy-ingress", false},
+				},
+			},
+		},
+		{
+			"should report no conflicts for the hosts claimed by no other ingress",
+			withCreationTime(newIstioIngress("test-namespace", "other-ingress", "other.company.com"), created),
+			&DomainClaimStatus{
+				Provider:  Istio,
+				Hosts:     []string{"other.company.com"},
+				Conflicts: []ClaimConflictStatus{},
+			},
+		},
+		{
+			"should report no hosts for the ingresses served by no provider",
+			newClassedIngress("other", ""),
+			&DomainClaimStatus{
+				Provider:  None,
+				Hosts:     []string{},
+				Conflicts: []ClaimConflictStatus{},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := helper.GetClaimStatus(test.ingress)
+			assert.Nil(t, err, "err should be nil")
+			assert.Equal(t, test.expected, status)
+		})
+	}
+}
+
+func TestIsClaimStatusUpdate(t *testing.T) {
+	oldIngress := newIstioIngress("test-namespace", "test-ingress", "test.company.com")
+	oldIngress.ResourceVersion = "1"
+
+	reported := oldIngress.DeepCopy()
+	reported.ResourceVersion = "2"
+	reported.Annotations[string(ClaimStatus)] = `{"provider":"istio","hosts":["test.company.com"]}`
+	assert.True(t, helper.IsClaimStatusUpdate(oldIngress, reported), "should only change the claim status")
+
+	edited := reported.DeepCopy()
+	edited.Spec.Rules[0].Host = "other.company.com"
+	assert.False(t, helper.IsClaimStatusUpdate(oldIngress, edited), "should change the rule hosts")
+
+	annotated := reported.DeepCopy()
+	annotated.Annotations[string(IngressClass)] = ATS
+	assert.False(t, helper.IsClaimStatusUpdate(oldIngress, annotated), "should change the other annotations")
+
+	unchanged := oldIngress.DeepCopy()
+	assert.False(t, helper.IsClaimStatusUpdate(oldIngress, unchanged), "should not change the claim status")
+}
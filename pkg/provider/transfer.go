@@ -0,0 +1,67 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	// ReleaseTo is the annotation on the owner ingress handing domains over to other ingresses. The value is a
+	// comma separated list of <domain>=<namespace>[/<ingress>] items, the domain is released to every ingress of
+	// the namespace when the ingress name is omitted.
+	ReleaseTo Annotation = "ingressclaim.yahoo.io/release-to"
+)
+
+// releasesDomain checks if the owner ingress releases the domain to the given ingress through the ReleaseTo
+// annotation
+func (h *Helper) releasesDomain(owner *networkingv1.Ingress, domain string, ingress *networkingv1.Ingress) bool {
+	annotationVal, exists := owner.Annotations[string(ReleaseTo)]
+	if !exists {
+		return false
+	}
+	for _, item := range strings.Split(annotationVal, ",") {
+		release := strings.SplitN(item, "=", 2)
+		if len(release) != 2 || h.sanitize(release[0]) != domain {
+			continue
+		}
+		target := strings.SplitN(strings.TrimSpace(release[1]), "/", 2)
+		if target[0] != ingress.Namespace {
+			continue
+		}
+		if len(target) == 1 || target[1] == ingress.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// isHandedOver checks if a domain claimed by both ingresses is being handed over from the cached owner ingress to
+// the ingress, in which case both may claim it until the handoff completes. A release on the ingress itself only
+// counts for the updates of an ingress whose cached version already claims the domain, looked up on the cache
+// index with the name 'index', so that the releasing ingress keeps its domain once the new owner exists while an
+// ingress cannot take a domain by releasing it to its owner.
+func (h *Helper) isHandedOver(index string, ingress *networkingv1.Ingress, domain string,
+	owner *networkingv1.Ingress, ownerDomain string) bool {
+	if h.releasesDomain(owner, ownerDomain, ingress) {
+		return true
+	}
+	return h.releasesDomain(ingress, domain, owner) && h.isClaimedInCache(index, ingress, domain)
+}
+
+// isClaimedInCache checks if the cached version of the ingress claims the domain, looked up on the cache index
+// with the name 'index'
+func (h *Helper) isClaimedInCache(index string, ingress *networkingv1.Ingress, domain string) bool {
+	cached, err := h.lookupIngressesByDomain(index, domain)
+	if err != nil {
+		return false
+	}
+	for _, cachedIngress := range cached {
+		if cachedIngress.Namespace == ingress.Namespace && cachedIngress.Name == ingress.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestReleasesDomain(t *testing.T) {
+	owner := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "test-blue",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				string(ReleaseTo): "App.company.com=test-namespace/test-green, api.company.com=test-other",
+			},
+		},
+	}
+	newIngress := func(namespace string, name string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		domain   string
+		input    *networkingv1.Ingress
+		expected bool
+	}{
+		{
+			"should release a domain to the named ingress",
+			"app.company.com",
+			newIngress("test-namespace", "test-green"),
+			true,
+		},
+		{
+			"should not release a domain to another ingress of the namespace",
+			"app.company.com",
+			newIngress("test-namespace", "test-red"),
+			false,
+		},
+		{
+			"should release a domain to any ingress of the namespace",
+			"api.company.com",
+			newIngress("test-other", "test-red"),
+			true,
+		},
+		{
+			"should not release a domain to another namespace",
+			"api.company.com",
+			newIngress("test-namespace", "test-green"),
+			false,
+		},
+		{
+			"should not release a domain that is not listed",
+			"web.company.com",
+			newIngress("test-namespace", "test-green"),
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, helper.releasesDomain(owner, test.domain, test.input), test.name)
+		})
+	}
+}
+
+func TestValidateHandedOverDomainClaims(t *testing.T) {
+	newATSIngress := func(namespace string, name string, release string) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Annotations: map[string]string{
+					string(DefaultDomain): "test-handoff.company.com",
+					string(Ports):         "80",
+				},
+			},
+		}
+		if release != "" {
+			ingress.Annotations[string(ReleaseTo)] = release
+		}
+		return ingress
+	}
+
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			ATS: helper.GetProviderByName(ATS).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newATSIngress("test-ns-ref", "test-blue", "test-handoff.company.com=test-ns-new/test-green"))
+
+	tests := []struct {
+		name     string
+		input    *networkingv1.Ingress
+		expected error
+	}{
+		{
+			"should pass for the ingress the domain is released to",
+			newATSIngress("test-ns-new", "test-green", ""),
+			nil,
+		},
+		{
+			"should fail for another ingress than the one the domain is released to",
+			newATSIngress("test-ns-new", "test-red", ""),
+			errors.New("Domain test-handoff.company.com already exists. Ingress test-blue in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+		{
+			"should fail for an ingress releasing the domain to its owner",
+			newATSIngress("test-ns-new", "test-red", "test-handoff.company.com=test-ns-ref/test-blue"),
+			errors.New("Domain test-handoff.company.com already exists. Ingress test-blue in namespace " +
+				"test-ns-ref owns this domain."),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := helper.validateDomainClaims(context.TODO(), test.input, helper.GetProvider(test.input).GetDomains(test.input))
+			if test.expected == nil {
+				assert.Nil(t, err, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected.Error(), err.Error(), test.name)
+			}
+		})
+	}
+
+	// once the new owner exists, the releasing ingress can still be updated while keeping the domain
+	helper.indexer.Add(newATSIngress("test-ns-new", "test-green", ""))
+	releasing := newATSIngress("test-ns-ref", "test-blue", "test-handoff.company.com=test-ns-new/test-green")
+	assert.Nil(t, helper.validateDomainClaims(context.TODO(), releasing,
+		helper.GetProvider(releasing).GetDomains(releasing)), "should pass for an update of the releasing ingress")
+
+	// an ingress admitted without the domain cannot take it over by releasing it to the owners
+	helper.indexer.Add(&networkingv1.Ingress{ObjectMeta: v1.ObjectMeta{Name: "test-red", Namespace: "test-ns-new"}})
+	selfReleasing := newATSIngress("test-ns-new", "test-red", "test-handoff.company.com=test-ns-ref/test-blue")
+	assert.NotNil(t, helper.validateDomainClaims(context.TODO(), selfReleasing,
+		helper.GetProvider(selfReleasing).GetDomains(selfReleasing)),
+		"should fail for an update releasing the domain to its owner")
+}
@@ -3,7 +3,8 @@
 package provider
 
 import (
-	"k8s.io/api/extensions/v1beta1"
+	"context"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 type Annotation string
@@ -13,16 +14,23 @@ const (
 	IngressClass Annotation = "kubernetes.io/ingress.class"
 )
 
+// Provider is implemented by every ingress claim provider. All the ingress arguments use the
+// networking.k8s.io/v1 Ingress as the internal model, see ToIngress for the conversion of other versions
 type Provider interface {
 	Name() string
 
-	ServesIngress(ingress *v1beta1.Ingress) bool
+	ServesIngress(ingress *networkingv1.Ingress) bool
 
-	GetDomains(ingress *v1beta1.Ingress) []string
+	GetDomains(ingress *networkingv1.Ingress) []string
 
 	DomainsIndexFunc(obj interface{}) ([]string, error)
 
-	ValidateSemantics(ingress *v1beta1.Ingress) error
+	ValidateSemantics(ingress *networkingv1.Ingress) error
 
-	ValidateDomainClaims(ingress *v1beta1.Ingress) error
+	// ValidateDomainClaims checks the domain claims of the ingress, the claim leases being bounded by ctx
+	ValidateDomainClaims(ctx context.Context, ingress *networkingv1.Ingress) error
+
+	// GetWarnings returns the non-fatal findings on the ingress, surfaced as admission warnings while the
+	// ingress is still admitted
+	GetWarnings(ingress *networkingv1.Ingress) []string
 }
@@ -0,0 +1,70 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// UpdateValidation defines which domains of an updated ingress the domain claim checks are enforced on
+type UpdateValidation string
+
+const (
+	// UpdateValidationFull enforces the checks on all the domains of the updated ingress
+	UpdateValidationFull UpdateValidation = "full"
+
+	// UpdateValidationDiff enforces the checks on the domains added by the update only, the conflicts of the
+	// domains the ingress already claimed are returned as warnings
+	UpdateValidationDiff UpdateValidation = "diff"
+)
+
+// ParseUpdateValidation returns the update validation with the given name
+func ParseUpdateValidation(name string) (UpdateValidation, error) {
+	switch validation := UpdateValidation(name); validation {
+	case UpdateValidationFull, UpdateValidationDiff:
+		return validation, nil
+	}
+	return "", fmt.Errorf("Unknown update validation: %s", name)
+}
+
+// SetUpdateValidation sets which domains of the updated ingresses the domain claim checks are enforced on
+func (h *Helper) SetUpdateValidation(validation UpdateValidation) {
+	h.updateValidation = validation
+}
+
+// GetUpdateValidation returns which domains of the updated ingresses the domain claim checks are enforced on
+func (h *Helper) GetUpdateValidation() UpdateValidation {
+	if h.updateValidation == "" {
+		return UpdateValidationFull
+	}
+	return h.updateValidation
+}
+
+// ValidateUpdatedDomainClaims performs the domain claim checks of the update of oldIngress into ingress served
+// by the provider p, enforced on the domains added by the update only. The domains oldIngress already claimed
+// do not fail the checks, their pre-existing conflicts are returned as warnings instead, so that an ingress
+// admitted with a conflict, e.g. while admitting all the ingresses, can still be edited. The domains are neither
+// leased nor reserved as pending for a dry run.
+func (h *Helper) ValidateUpdatedDomainClaims(ctx context.Context, p Provider, oldIngress *networkingv1.Ingress,
+	ingress *networkingv1.Ingress, dryRun bool) ([]string, error) {
+	existing := map[string]bool{}
+	for _, domain := range h.GetProvider(oldIngress).GetDomains(oldIngress) {
+		existing[domain] = true
+	}
+	tolerated, err := h.validateClaims(ctx, ingress, p.GetDomains(ingress), existing, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	warnings := []string{}
+	seen := map[string]bool{}
+	for _, rejection := range tolerated {
+		if !seen[rejection.Message] {
+			seen[rejection.Message] = true
+			warnings = append(warnings, "Pre-existing conflict not enforced on update: "+rejection.Message)
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseUpdateValidation(t *testing.T) {
+	validation, err := ParseUpdateValidation("diff")
+	assert.Nil(t, err, "err should be nil")
+	assert.Equal(t, UpdateValidationDiff, validation)
+
+	_, err = ParseUpdateValidation("partial")
+	assert.NotNil(t, err, "should fail for an unknown update validation")
+
+	assert.Equal(t, UpdateValidationFull, helper.GetUpdateValidation(), "should enforce all the domains by default")
+}
+
+func TestValidateUpdatedDomainClaims(t *testing.T) {
+	helper.SetIndexer(cache.NewIndexer(
+		cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{
+			Istio: helper.GetProviderByName(Istio).DomainsIndexFunc,
+		}))
+	helper.indexer.Add(newIstioIngress("test-namespace", "owner-ingress", "taken.company.com", "other.company.com"))
+
+	oldIngress := newIstioIngress("test-namespace", "test-ingress", "taken.company.com")
+	tests := []struct {
+		name     string
+		ingress  *networkingv1.Ingress
+		warnings []string
+		expected string
+	}{
+		{
+			"should warn about the pre-existing conflict of an unrelated edit",
+			newIstioIngress("test-namespace", "test-ingress", "taken.company.com", "free.company.com"),
+			[]string{"Pre-existing conflict not enforced on update: Domain taken.company.com already exists. " +
+				"Ingress owner-ingress in namespace test-namespace owns this domain."},
+			"",
+		},
+		{
+			"should pass for an update dropping the conflicting domain",
+			newIstioIngress("test-namespace", "test-ingress", "free.company.com"),
+			[]string{},
+			"",
+		},
+		{
+			"should fail for a conflicting domain added by the update",
+			newIstioIngress("test-namespace", "test-ingress", "taken.company.com", "other.company.com"),
+			nil,
+			"Domain other.company.com already exists. Ingress owner-ingress in namespace test-namespace owns " +
+				"this domain.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := helper.ValidateUpdatedDomainClaims(context.TODO(), helper.GetProviderByName(Istio), oldIngress,
+				test.ingress, false)
+			if test.expected == "" {
+				assert.Nil(t, err, test.name)
+				assert.Equal(t, test.warnings, warnings, test.name)
+			} else if assert.NotNil(t, err, test.name) {
+				assert.Equal(t, test.expected, err.Error(), test.name)
+			}
+		})
+	}
+}
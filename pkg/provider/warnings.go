@@ -0,0 +1,31 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// getCommonWarnings returns the non-fatal findings shared by all the providers, such as the deprecated
+// annotations on the ingress
+func (h *Helper) getCommonWarnings(ingress *networkingv1.Ingress) []string {
+	warnings := []string{}
+	if _, exists := ingress.Annotations[string(IngressClass)]; exists {
+		warnings = append(warnings, fmt.Sprintf("Annotation %s is deprecated, use spec.ingressClassName instead.",
+			IngressClass))
+	}
+	return warnings
+}
+
+// getSanitizeWarning returns the finding on a domain of the given annotation which is silently rewritten by
+// sanitize, such as a domain with uppercase letters, "" if the domain is left as is
+func (h *Helper) getSanitizeWarning(annotation Annotation, domain string) string {
+	domain = strings.TrimSpace(domain)
+	if sanitized := h.sanitize(domain); sanitized != domain {
+		return fmt.Sprintf("Domain %q of annotation %s is normalized to %q.", domain, annotation, sanitized)
+	}
+	return ""
+}
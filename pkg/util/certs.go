@@ -0,0 +1,200 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package util
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// CertReloader serves the TLS certificate and the client CA pool loaded from files, and swaps them in when
+// the files change on disk such as on the rotation of a mounted secret. The handshakes of new connections pick
+// up the reloaded files while the established connections are left untouched.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	caPool   *x509.CertPool
+	caPEM    []byte
+	contents []byte
+}
+
+// NewCertReloader returns a CertReloader with the cert/key pair and the client CA file loaded
+func NewCertReloader(certFile string, keyFile string, caFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files and swaps the certificate and the client CA pool in when any file changed. It
+// returns whether the files changed, the previous certificate is kept when the files cannot be loaded.
+func (r *CertReloader) Reload() (bool, error) {
+	certPEM, err := ioutil.ReadFile(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read the server cert file: %s", err.Error())
+	}
+	keyPEM, err := ioutil.ReadFile(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read the server key file: %s", err.Error())
+	}
+	caPEM, err := ioutil.ReadFile(r.caFile)
+	if err != nil {
+		return false, fmt.Errorf("Unable to read the client CA cert file: %s", err.Error())
+	}
+
+	contents := bytes.Join([][]byte{certPEM, keyPEM, caPEM}, nil)
+	r.mu.RLock()
+	unchanged := bytes.Equal(contents, r.contents)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("Unable to load the server cert and key: %s", err.Error())
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("Unable to parse the server cert: %s", err.Error())
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return false, errors.New("Unable to load any certificate from the client CA cert file")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.leaf, r.caPool, r.caPEM, r.contents = &cert, leaf, caPool, caPEM, contents
+	return true, nil
+}
+
+// Watch polls the files every interval until stop is closed and reloads them on change. The notify callback
+// is invoked with the outcome of every reload of changed or unreadable files.
+func (r *CertReloader) Watch(interval time.Duration, stop <-chan struct{}, notify func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if changed, err := r.Reload(); changed || err != nil {
+				notify(err)
+			}
+		}
+	}
+}
+
+// GetCertificate returns the current server certificate, to be set on tls.Config
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// GetConfigForClient returns a tls.Config callback serving the base config with the current server certificate
+// and client CA pool
+func (r *CertReloader) GetConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.GetCertificate = r.GetCertificate
+		config.ClientCAs = r.ClientCAs()
+		return config, nil
+	}
+}
+
+// Certificate returns the parsed current server certificate
+func (r *CertReloader) Certificate() (*x509.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.leaf, nil
+}
+
+// ClientCAs returns the current client CA pool
+func (r *CertReloader) ClientCAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.caPool
+}
+
+// GenerateCerts generates a self-signed CA and a server cert/key pair signed by the CA for the given DNS names,
+// valid for the given duration. It returns the PEM encoded CA cert, server cert and server key.
+func GenerateCerts(dnsNames []string, validity time.Duration) ([]byte, []byte, []byte, error) {
+	if len(dnsNames) == 0 {
+		return nil, nil, nil, errors.New("Unable to generate a server cert without any DNS name")
+	}
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(validity)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to generate the CA key: %s", err.Error())
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(notBefore.UnixNano()),
+		Subject:               pkix.Name{CommonName: dnsNames[0] + "-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to create the CA cert: %s", err.Error())
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to parse the CA cert: %s", err.Error())
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to generate the server key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(notBefore.UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to create the server cert: %s", err.Error())
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Unable to encode the server key: %s", err.Error())
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCert writes a self-signed cert/key pair with the given serial number, the cert doubles as the client CA
+func writeTestCert(t *testing.T, dir string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "k8s-ingress-claim"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server.crt"), certPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server-key.pem"), keyPEM, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), certPEM, 0600))
+}
+
+func newTestCertReloader(t *testing.T) (*CertReloader, string) {
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	writeTestCert(t, dir, 1)
+	r, err := NewCertReloader(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server-key.pem"),
+		filepath.Join(dir, "ca.crt"))
+	assert.NoError(t, err)
+	return r, dir
+}
+
+func TestNewCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = NewCertReloader(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server-key.pem"),
+		filepath.Join(dir, "ca.crt"))
+	assert.Error(t, err, "should fail when the files do not exist")
+}
+
+func TestCertReloaderReload(t *testing.T) {
+	r, dir := newTestCertReloader(t)
+	defer os.RemoveAll(dir)
+
+	cert, err := r.Certificate()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cert.SerialNumber.Int64())
+
+	changed, err := r.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed, "should not reload the unchanged files")
+
+	writeTestCert(t, dir, 2)
+	changed, err = r.Reload()
+	assert.NoError(t, err)
+	assert.True(t, changed, "should reload the rotated files")
+	cert, err = r.Certificate()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), cert.SerialNumber.Int64())
+
+	served, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, cert.Raw, served.Certificate[0])
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "server-key.pem"), []byte("invalid"), 0600))
+	changed, err = r.Reload()
+	assert.Error(t, err, "should fail to load an invalid key")
+	assert.False(t, changed)
+	cert, err = r.Certificate()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), cert.SerialNumber.Int64(), "should keep the previous certificate")
+}
+
+func TestCertReloaderGetConfigForClient(t *testing.T) {
+	r, dir := newTestCertReloader(t)
+	defer os.RemoveAll(dir)
+
+	base := &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}
+	base.GetConfigForClient = r.GetConfigForClient(base)
+
+	config, err := base.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.Nil(t, config.GetConfigForClient)
+	assert.Equal(t, r.ClientCAs(), config.ClientCAs)
+
+	writeTestCert(t, dir, 2)
+	_, err = r.Reload()
+	assert.NoError(t, err)
+	config, err = base.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, r.ClientCAs(), config.ClientCAs, "should serve the reloaded client CA pool")
+	served, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	cert, err := r.Certificate()
+	assert.NoError(t, err)
+	assert.Equal(t, cert.Raw, served.Certificate[0])
+}
+
+func TestGenerateCerts(t *testing.T) {
+	_, _, _, err := GenerateCerts(nil, time.Hour)
+	assert.Error(t, err, "should fail without any DNS name")
+
+	caPEM, certPEM, keyPEM, err := GenerateCerts([]string{"k8s-ingress-claim.default.svc"}, time.Hour)
+	assert.NoError(t, err)
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.NoError(t, err, "should generate a matching cert/key pair")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(caPEM))
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName: "k8s-ingress-claim.default.svc",
+		Roots:   roots,
+	})
+	assert.NoError(t, err, "should generate a server cert signed by the CA")
+}
@@ -0,0 +1,26 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseKeyValues parses a comma separated list of key=value pairs as used by the map-like command line flags.
+// Whitespaces around keys and values are ignored.
+func ParseKeyValues(s string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid key=value pair: %s", item)
+		}
+		pairs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return pairs, nil
+}
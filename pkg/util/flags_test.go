@@ -0,0 +1,57 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		err      bool
+	}{
+		{
+			"should return an empty map for an empty string",
+			"",
+			map[string]string{},
+			false,
+		},
+		{
+			"should parse the pairs ignoring whitespaces",
+			"ATS = ats.apache.org/ingress-controller, istio=istio.io/ingress-controller,",
+			map[string]string{
+				"ATS":   "ats.apache.org/ingress-controller",
+				"istio": "istio.io/ingress-controller",
+			},
+			false,
+		},
+		{
+			"should fail for an item without a value separator",
+			"ATS",
+			nil,
+			true,
+		},
+		{
+			"should fail for an item without a key",
+			"=istio",
+			nil,
+			true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := ParseKeyValues(test.input)
+			if test.err {
+				assert.NotNil(t, err, test.name)
+			} else {
+				assert.Nil(t, err, test.name)
+			}
+			assert.Equal(t, test.expected, actual, test.name)
+		})
+	}
+}
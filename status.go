@@ -0,0 +1,116 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// claimStatusReporter writes the claim status of the cached ingresses back to their ClaimStatus annotation, for
+// the users to see the hosts their ingresses claim and the conflicts detected in the cache
+type claimStatusReporter struct {
+	// client is the REST client of the Ingress api group/version watched by the informer
+	client  rest.Interface
+	indexer cache.Indexer
+}
+
+// newClaimStatusReporter returns a reporter of the ingresses of the indexer patched through the REST client
+func newClaimStatusReporter(client rest.Interface, indexer cache.Indexer) *claimStatusReporter {
+	return &claimStatusReporter{
+		client:  client,
+		indexer: indexer,
+	}
+}
+
+// sync patches the ClaimStatus annotation of the cached ingresses whose claim status changed, all the ingresses
+// are reported before returning the number of ingresses failing to be reported
+func (r *claimStatusReporter) sync() error {
+	failed := 0
+	for _, obj := range r.indexer.List() {
+		if err := r.report(obj); err != nil {
+			log.Errorf("Unable to report the claim status: %s", err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Unable to report the claim status of %d ingresses", failed)
+	}
+	return nil
+}
+
+// report patches the ClaimStatus annotation of the cached ingress when its claim status changed
+func (r *claimStatusReporter) report(obj interface{}) error {
+	ingress, err := provider.ToIngress(obj)
+	if err != nil {
+		return err
+	}
+	status, err := helper.GetClaimStatus(ingress)
+	if err != nil {
+		return fmt.Errorf("Unable to check the claims of Ingress %s in namespace %s: %s", ingress.Name,
+			ingress.Namespace, err.Error())
+	}
+	value, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if ingress.Annotations[string(provider.ClaimStatus)] == string(value) {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				string(provider.ClaimStatus): string(value),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	err = r.client.Patch(types.MergePatchType).
+		Namespace(ingress.Namespace).
+		Resource("ingresses").
+		Name(ingress.Name).
+		Body(patch).
+		Do(context.TODO()).
+		Error()
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to patch the claim status of Ingress %s in namespace %s: %s", ingress.Name,
+			ingress.Namespace, err.Error())
+	}
+	log.Debugf("Reported the claim status of Ingress %s in namespace %s: %s", ingress.Name, ingress.Namespace,
+		value)
+	return nil
+}
+
+// run reports the claim status of the cached ingresses now and then every interval until stop is closed
+func (r *claimStatusReporter) run(interval time.Duration, stop <-chan struct{}) {
+	if err := r.sync(); err != nil {
+		log.Error(err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.sync(); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
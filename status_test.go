@@ -0,0 +1,104 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yahoo/k8s-ingress-claim/pkg/provider"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestClaimStatusReporterSync(t *testing.T) {
+	created := time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	legacyIngress := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:              "legacy-ingress",
+			Namespace:         "test-namespace",
+			CreationTimestamp: v1.NewTime(created),
+			Annotations: map[string]string{
+				string(provider.IngressClass): provider.Istio,
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{Host: "taken.company.com"}},
+		},
+	}
+	duplicateIngress := legacyIngress.DeepCopy()
+	duplicateIngress.Name = "duplicate-ingress"
+	duplicateIngress.CreationTimestamp = v1.NewTime(created.Add(time.Hour))
+	reportedIngress := legacyIngress.DeepCopy()
+	reportedIngress.Name = "reported-ingress"
+	reportedIngress.Spec.Rules[0].Host = "free.company.com"
+	reportedIngress.Annotations[string(provider.ClaimStatus)] = `{"provider":"istio","hosts":["free.company.com"]}`
+
+	statusIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc,
+		cache.Indexers{provider.Istio: helper.GetProviderByName(provider.Istio).DomainsIndexFunc})
+	statusIndexer.Add(legacyIngress)
+	statusIndexer.Add(duplicateIngress)
+	statusIndexer.Add(reportedIngress)
+	helper.SetIndexer(statusIndexer)
+
+	// record the claim status patched on every ingress
+	patched := map[string]provider.DomainClaimStatus{}
+	client := &fake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         networkingv1.SchemeGroupVersion,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPatch, req.Method, "should patch the ingress")
+			patch := struct {
+				Metadata v1.ObjectMeta `json:"metadata"`
+			}{}
+			if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+				return nil, err
+			}
+			status := provider.DomainClaimStatus{}
+			if err := json.Unmarshal([]byte(patch.Metadata.Annotations[string(provider.ClaimStatus)]),
+				&status); err != nil {
+				return nil, err
+			}
+			patched[req.URL.Path] = status
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+			}, nil
+		}),
+	}
+
+	reporter := newClaimStatusReporter(client, statusIndexer)
+	assert.Nil(t, reporter.sync(), "err should be nil")
+	assert.Len(t, patched, 2, "should only patch the ingresses whose claim status changed")
+	assert.Equal(t, provider.DomainClaimStatus{
+		Provider: provider.Istio,
+		Hosts:    []string{"taken.company.com"},
+		Conflicts: []provider.ClaimConflictStatus{{
+			Reason:     provider.ReasonDomainConflict,
+			Host:       "taken.company.com",
+			Owner:      "test-namespace/duplicate-ingress",
+			FirstClaim: true,
+		}},
+	}, patched["/namespaces/test-namespace/ingresses/legacy-ingress"],
+		"should report the legacy duplicate as a conflict of the first claim")
+	assert.Equal(t, provider.DomainClaimStatus{
+		Provider: provider.Istio,
+		Hosts:    []string{"taken.company.com"},
+		Conflicts: []provider.ClaimConflictStatus{{
+			Reason: provider.ReasonDomainConflict,
+			Host:   "taken.company.com",
+			Owner:  "test-namespace/legacy-ingress",
+		}},
+	}, patched["/namespaces/test-namespace/ingresses/duplicate-ingress"],
+		"should report the first claim as a conflict of the legacy duplicate")
+}
@@ -0,0 +1,360 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yahoo/k8s-ingress-claim/pkg/util"
+
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	admregclient "k8s.io/client-go/kubernetes/typed/admissionregistration/v1"
+	coreclient "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// webhookSuffix is appended to the configuration name to name the registered webhook
+	webhookSuffix = ".yahoo.io"
+
+	// generatedCertValidity is the validity of the server cert generated when no CA file is given
+	generatedCertValidity = 365 * 24 * time.Hour
+
+	// generatedCertRenewal is the time before its expiry the generated server cert is renewed
+	generatedCertRenewal = 30 * 24 * time.Hour
+
+	// webhookCAKey is the key of the generated CA in the Secret sharing the generated certs
+	webhookCAKey = "ca.crt"
+)
+
+// webhookRegistrar creates and updates the ValidatingWebhookConfiguration of the webhook and keeps its
+// caBundle in sync with the CA that signs the serving certificate
+type webhookRegistrar struct {
+	client admregclient.ValidatingWebhookConfigurationInterface
+
+	name              string
+	serviceNamespace  string
+	serviceName       string
+	operations        []admregv1.OperationType
+	failurePolicy     admregv1.FailurePolicyType
+	namespaceSelector *v1.LabelSelector
+
+	// caBundle returns the PEM encoded CA that signs the serving certificate
+	caBundle func() ([]byte, error)
+
+	// secrets holds the certSecret Secret sharing the generated certs, written into certDir, nil when the certs
+	// are not generated
+	secrets    coreclient.SecretInterface
+	certSecret string
+	certDir    string
+}
+
+// newWebhookRegistrar returns a registrar of the named configuration for the namespace/name service, the
+// comma separated operations, the failure policy and the namespace label selector
+func newWebhookRegistrar(client admregclient.ValidatingWebhookConfigurationInterface, name string,
+	service string, operations string, failurePolicy string, namespaceSelector string) (*webhookRegistrar, error) {
+	wr := &webhookRegistrar{
+		client: client,
+		name:   name,
+	}
+
+	serviceParts := strings.Split(service, "/")
+	if len(serviceParts) != 2 || serviceParts[0] == "" || serviceParts[1] == "" {
+		return nil, fmt.Errorf("Invalid webhook service: %s, expected <namespace>/<name>", service)
+	}
+	wr.serviceNamespace, wr.serviceName = serviceParts[0], serviceParts[1]
+
+	for _, operation := range strings.Split(operations, ",") {
+		switch op := admregv1.OperationType(strings.ToUpper(strings.TrimSpace(operation))); op {
+		case admregv1.Create, admregv1.Update, admregv1.Delete, admregv1.OperationAll:
+			wr.operations = append(wr.operations, op)
+		case "":
+		default:
+			return nil, fmt.Errorf("Invalid webhook operation: %s, expected one of: CREATE, UPDATE, DELETE, *",
+				operation)
+		}
+	}
+	if len(wr.operations) == 0 {
+		return nil, fmt.Errorf("No webhook operation is set")
+	}
+
+	switch policy := admregv1.FailurePolicyType(failurePolicy); policy {
+	case admregv1.Fail, admregv1.Ignore:
+		wr.failurePolicy = policy
+	default:
+		return nil, fmt.Errorf("Invalid webhook failure policy: %s, expected one of: Fail, Ignore", failurePolicy)
+	}
+
+	selector, err := v1.ParseToLabelSelector(namespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid webhook namespace selector: %s", err.Error())
+	}
+	wr.namespaceSelector = selector
+	return wr, nil
+}
+
+// dnsNames returns the DNS names of the webhook service
+func (wr *webhookRegistrar) dnsNames() []string {
+	return []string{
+		wr.serviceName + "." + wr.serviceNamespace + ".svc",
+		wr.serviceName + "." + wr.serviceNamespace + ".svc.cluster.local",
+		wr.serviceName + "." + wr.serviceNamespace,
+		wr.serviceName,
+	}
+}
+
+// webhooks returns the desired webhooks of the configuration with the given caBundle
+func (wr *webhookRegistrar) webhooks(caBundle []byte) []admregv1.ValidatingWebhook {
+	path := "/"
+	port := int32(443)
+	sideEffects := admregv1.SideEffectClassNoneOnDryRun
+	failurePolicy := wr.failurePolicy
+	// the fields defaulted by the apiserver are set for the live webhooks to compare equal
+	matchPolicy := admregv1.Equivalent
+	scope := admregv1.AllScopes
+	timeoutSeconds := int32(10)
+	return []admregv1.ValidatingWebhook{
+		{
+			Name:                    wr.name + webhookSuffix,
+			AdmissionReviewVersions: []string{"v1", "v1beta1"},
+			SideEffects:             &sideEffects,
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       wr.namespaceSelector,
+			ObjectSelector:          &v1.LabelSelector{},
+			TimeoutSeconds:          &timeoutSeconds,
+			Rules: []admregv1.RuleWithOperations{
+				{
+					Operations: wr.operations,
+					Rule: admregv1.Rule{
+						APIGroups:   []string{"extensions", "networking.k8s.io"},
+						APIVersions: []string{"v1beta1", "v1"},
+						Resources:   []string{"ingresses"},
+						Scope:       &scope,
+					},
+				},
+			},
+			ClientConfig: admregv1.WebhookClientConfig{
+				Service: &admregv1.ServiceReference{
+					Namespace: wr.serviceNamespace,
+					Name:      wr.serviceName,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: caBundle,
+			},
+		},
+	}
+}
+
+// sync creates or updates the configuration when it does not exist yet or its webhooks differ from the desired
+// webhooks, such as after a rotation of the caBundle, a change of the webhook flags or an update by another replica
+func (wr *webhookRegistrar) sync() error {
+	caBundle, err := wr.caBundle()
+	if err != nil {
+		return err
+	}
+
+	config, err := wr.client.Get(context.TODO(), wr.name, v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		config = &admregv1.ValidatingWebhookConfiguration{
+			ObjectMeta: v1.ObjectMeta{
+				Name:   wr.name,
+				Labels: map[string]string{"app": wr.serviceName},
+			},
+			Webhooks: wr.webhooks(caBundle),
+		}
+		if _, err = wr.client.Create(context.TODO(), config, v1.CreateOptions{}); err != nil {
+			return fmt.Errorf("Unable to create the ValidatingWebhookConfiguration %s: %s", wr.name, err.Error())
+		}
+		log.Infof("Created the ValidatingWebhookConfiguration %s", wr.name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to get the ValidatingWebhookConfiguration %s: %s", wr.name, err.Error())
+	}
+	if wr.isRegistered(config, caBundle) {
+		return nil
+	}
+
+	config = config.DeepCopy()
+	config.Webhooks = wr.webhooks(caBundle)
+	if _, err = wr.client.Update(context.TODO(), config, v1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("Unable to update the ValidatingWebhookConfiguration %s: %s", wr.name, err.Error())
+	}
+	log.Infof("Updated the ValidatingWebhookConfiguration %s", wr.name)
+	return nil
+}
+
+// isRegistered checks if the live configuration holds the desired webhooks of the registrar with the caBundle
+func (wr *webhookRegistrar) isRegistered(config *admregv1.ValidatingWebhookConfiguration, caBundle []byte) bool {
+	return apiequality.Semantic.DeepEqual(config.Webhooks, wr.webhooks(caBundle))
+}
+
+// run syncs the configuration every interval until stop is closed, along with the generated certs renewed
+// before their expiry
+func (wr *webhookRegistrar) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if wr.secrets != nil {
+				if _, _, err := wr.loadGeneratedCerts(); err != nil {
+					log.Errorf("Unable to renew the generated webhook certs: %s", err.Error())
+				}
+			}
+			if err := wr.sync(); err != nil {
+				log.Errorf("Unable to sync the webhook registration: %s", err.Error())
+			}
+		}
+	}
+}
+
+// loadWebhookCA sets the registered caBundle to the content of the CA file, re-read on every sync
+func (wr *webhookRegistrar) loadWebhookCA(caFile string) {
+	wr.caBundle = func() ([]byte, error) {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the webhook CA file: %s", err.Error())
+		}
+		return caPEM, nil
+	}
+}
+
+// generateWebhookCerts registers a generated CA along with a server cert for the webhook service, shared by
+// all the replicas through the named Secret of the service namespace: the first replica generates and creates
+// it, the others load it. The server cert and key are written into the cert directory, whose cert and key file
+// paths are returned, and are renewed by run before their expiry.
+func (wr *webhookRegistrar) generateWebhookCerts(secrets coreclient.SecretInterface, secretName string,
+	certDir string) (string, string, error) {
+	wr.secrets, wr.certSecret, wr.certDir = secrets, secretName, certDir
+	return wr.loadGeneratedCerts()
+}
+
+// loadGeneratedCerts loads the generated certs from the Secret, generating them when the Secret does not exist
+// or renewing them when the server cert expires within generatedCertRenewal, and writes the server cert and key
+// into the cert directory when they changed
+func (wr *webhookRegistrar) loadGeneratedCerts() (string, string, error) {
+	secret, err := wr.secrets.Get(context.TODO(), wr.certSecret, v1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err = wr.createGeneratedCerts()
+	} else if err == nil && wr.isExpiring(secret.Data[corev1.TLSCertKey]) {
+		secret, err = wr.renewGeneratedCerts(secret)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("Unable to load the generated webhook certs from the Secret %s: %s",
+			wr.certSecret, err.Error())
+	}
+	caPEM, certPEM, keyPEM := secret.Data[webhookCAKey], secret.Data[corev1.TLSCertKey],
+		secret.Data[corev1.TLSPrivateKeyKey]
+	if len(caPEM) == 0 || len(certPEM) == 0 || len(keyPEM) == 0 {
+		return "", "", fmt.Errorf("The Secret %s misses the generated webhook certs", wr.certSecret)
+	}
+
+	if err = os.MkdirAll(wr.certDir, 0700); err != nil {
+		return "", "", fmt.Errorf("Unable to create the server cert directory: %s", err.Error())
+	}
+	certFile, keyFile := filepath.Join(wr.certDir, "server.crt"), filepath.Join(wr.certDir, "server-key.pem")
+	if err = writeChangedFile(keyFile, keyPEM, 0600); err != nil {
+		return "", "", fmt.Errorf("Unable to write the server key file: %s", err.Error())
+	}
+	if err = writeChangedFile(certFile, certPEM, 0644); err != nil {
+		return "", "", fmt.Errorf("Unable to write the server cert file: %s", err.Error())
+	}
+	wr.caBundle = func() ([]byte, error) {
+		return caPEM, nil
+	}
+	return certFile, keyFile, nil
+}
+
+// createGeneratedCerts generates the certs into the Secret, loading the Secret created by another replica first
+func (wr *webhookRegistrar) createGeneratedCerts() (*corev1.Secret, error) {
+	caPEM, certPEM, keyPEM, err := util.GenerateCerts(wr.dnsNames(), generatedCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := wr.secrets.Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   wr.certSecret,
+			Labels: map[string]string{"app": wr.serviceName},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			webhookCAKey:            caPEM,
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}, v1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return wr.secrets.Get(context.TODO(), wr.certSecret, v1.GetOptions{})
+	}
+	return secret, err
+}
+
+// renewGeneratedCerts generates new certs into the Secret, loading the Secret renewed by another replica first.
+// The registered caBundle keeps the previous CA along with the new one, for the replicas still serving the
+// previous cert until they load the new one.
+func (wr *webhookRegistrar) renewGeneratedCerts(secret *corev1.Secret) (*corev1.Secret, error) {
+	caPEM, certPEM, keyPEM, err := util.GenerateCerts(wr.dnsNames(), generatedCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	if previous, _ := pem.Decode(secret.Data[webhookCAKey]); previous != nil {
+		caPEM = append(caPEM, pem.EncodeToMemory(previous)...)
+	}
+	secret = secret.DeepCopy()
+	secret.Data = map[string][]byte{
+		webhookCAKey:            caPEM,
+		corev1.TLSCertKey:       certPEM,
+		corev1.TLSPrivateKeyKey: keyPEM,
+	}
+	renewed, err := wr.secrets.Update(context.TODO(), secret, v1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return wr.secrets.Get(context.TODO(), wr.certSecret, v1.GetOptions{})
+	}
+	if err == nil {
+		log.Infof("Renewed the generated webhook certs of the Secret %s", wr.certSecret)
+	}
+	return renewed, err
+}
+
+// isExpiring checks if the PEM encoded cert expires within generatedCertRenewal, an unreadable cert is renewed
+func (wr *webhookRegistrar) isExpiring(certPEM []byte) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	return time.Now().Add(generatedCertRenewal).After(cert.NotAfter)
+}
+
+// writeChangedFile writes the data into the file unless it already holds it, through a renamed temporary file so
+// that the file is never read half written
+func writeChangedFile(name string, data []byte, perm os.FileMode) error {
+	if current, err := ioutil.ReadFile(name); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
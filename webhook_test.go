@@ -0,0 +1,249 @@
+// Copyright 2017 Yahoo Holdings Inc.
+// Licensed under the terms of the 3-Clause BSD License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yahoo/k8s-ingress-claim/pkg/util"
+	admregv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewWebhookRegistrar(t *testing.T) {
+	tests := []struct {
+		name              string
+		service           string
+		operations        string
+		failurePolicy     string
+		namespaceSelector string
+		err               bool
+	}{
+		{
+			"should accept the default flags",
+			"default/k8s-ingress-claim",
+			"CREATE,UPDATE",
+			"Fail",
+			"",
+			false,
+		},
+		{
+			"should accept lower case operations and a namespace selector",
+			"ingress/k8s-ingress-claim",
+			"create, update, delete",
+			"Ignore",
+			"ingressclaim.yahoo.io/skip notin (true)",
+			false,
+		},
+		{
+			"should fail for a service without a namespace",
+			"k8s-ingress-claim",
+			"CREATE",
+			"Fail",
+			"",
+			true,
+		},
+		{
+			"should fail for an unknown operation",
+			"default/k8s-ingress-claim",
+			"CREATE,PATCH",
+			"Fail",
+			"",
+			true,
+		},
+		{
+			"should fail without any operation",
+			"default/k8s-ingress-claim",
+			"",
+			"Fail",
+			"",
+			true,
+		},
+		{
+			"should fail for an unknown failure policy",
+			"default/k8s-ingress-claim",
+			"CREATE",
+			"Retry",
+			"",
+			true,
+		},
+		{
+			"should fail for an invalid namespace selector",
+			"default/k8s-ingress-claim",
+			"CREATE",
+			"Fail",
+			"team in (",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := newWebhookRegistrar(nil, "k8s-ingress-claim", test.service, test.operations, test.failurePolicy,
+			test.namespaceSelector)
+		assert.Equal(t, test.err, err != nil, test.name)
+	}
+}
+
+func TestWebhookRegistrarSync(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	client := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	wr, err := newWebhookRegistrar(client, "k8s-ingress-claim", "ingress/k8s-ingress-claim", "CREATE,UPDATE",
+		"Ignore", "team=a")
+	assert.NoError(t, err)
+	caBundle := []byte("ca-1")
+	wr.caBundle = func() ([]byte, error) {
+		return caBundle, nil
+	}
+
+	assert.NoError(t, wr.sync(), "should create the configuration")
+	config, err := client.Get(context.TODO(), "k8s-ingress-claim", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, config.Webhooks, 1)
+	webhook := config.Webhooks[0]
+	assert.Equal(t, "k8s-ingress-claim.yahoo.io", webhook.Name)
+	assert.Equal(t, admregv1.Ignore, *webhook.FailurePolicy)
+	assert.Equal(t, map[string]string{"team": "a"}, webhook.NamespaceSelector.MatchLabels)
+	assert.Equal(t, []admregv1.OperationType{admregv1.Create, admregv1.Update}, webhook.Rules[0].Operations)
+	assert.Equal(t, "ingress", webhook.ClientConfig.Service.Namespace)
+	assert.Equal(t, "k8s-ingress-claim", webhook.ClientConfig.Service.Name)
+	assert.Equal(t, []byte("ca-1"), webhook.ClientConfig.CABundle)
+
+	caBundle = []byte("ca-2")
+	assert.NoError(t, wr.sync(), "should update the rotated caBundle")
+	config, err = client.Get(context.TODO(), "k8s-ingress-claim", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca-2"), config.Webhooks[0].ClientConfig.CABundle)
+
+	config.Webhooks[0].ClientConfig.CABundle = []byte("edited")
+	_, err = client.Update(context.TODO(), config, v1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, wr.sync(), "should restore the caBundle edited in the live configuration")
+	config, err = client.Get(context.TODO(), "k8s-ingress-claim", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca-2"), config.Webhooks[0].ClientConfig.CABundle)
+	clientset.ClearActions()
+	assert.NoError(t, wr.sync(), "err should be nil")
+	for _, action := range clientset.Actions() {
+		assert.NotEqual(t, "update", action.GetVerb(), "should not update an unchanged configuration")
+	}
+
+	wr.failurePolicy = admregv1.Fail
+	assert.NoError(t, wr.sync(), "should update the changed failure policy")
+	config, err = client.Get(context.TODO(), "k8s-ingress-claim", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, admregv1.Fail, *config.Webhooks[0].FailurePolicy)
+
+	assert.NoError(t, client.Delete(context.TODO(), "k8s-ingress-claim", v1.DeleteOptions{}))
+	assert.NoError(t, wr.sync(), "should recreate the deleted configuration")
+	config, err = client.Get(context.TODO(), "k8s-ingress-claim", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca-2"), config.Webhooks[0].ClientConfig.CABundle)
+}
+
+func TestWebhookRegistrarLoadWebhookCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+
+	wr, err := newWebhookRegistrar(nil, "k8s-ingress-claim", "default/k8s-ingress-claim", "CREATE", "Fail", "")
+	assert.NoError(t, err)
+
+	wr.loadWebhookCA(caFile)
+	_, err = wr.caBundle()
+	assert.Error(t, err, "should fail when the CA file does not exist")
+	assert.NoError(t, ioutil.WriteFile(caFile, []byte("ca"), 0600))
+	caBundle, err := wr.caBundle()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca"), caBundle, "should read the CA file")
+}
+
+func TestWebhookRegistrarGenerateWebhookCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secrets := fake.NewSimpleClientset().CoreV1().Secrets("default")
+
+	// every replica registers the CA generated by the first one
+	caBundles := [][]byte{}
+	for _, replica := range []string{"replica-1", "replica-2"} {
+		wr, err := newWebhookRegistrar(nil, "k8s-ingress-claim", "default/k8s-ingress-claim", "CREATE", "Fail", "")
+		assert.NoError(t, err)
+		certFile, keyFile, err := wr.generateWebhookCerts(secrets, "k8s-ingress-claim-generated-certs",
+			filepath.Join(dir, replica))
+		assert.NoError(t, err, "should generate or load the certs")
+		assert.Equal(t, filepath.Join(dir, replica, "server.crt"), certFile)
+		_, err = os.Stat(certFile)
+		assert.NoError(t, err, "should write the generated server cert")
+		_, err = os.Stat(keyFile)
+		assert.NoError(t, err, "should write the generated server key")
+
+		caBundle, err := wr.caBundle()
+		assert.NoError(t, err)
+		assert.Contains(t, string(caBundle), "BEGIN CERTIFICATE")
+		caBundles = append(caBundles, caBundle)
+	}
+	assert.Equal(t, caBundles[0], caBundles[1], "should share the generated CA across the replicas")
+
+	secret, err := secrets.Get(context.TODO(), "k8s-ingress-claim-generated-certs", v1.GetOptions{})
+	assert.NoError(t, err, "should store the generated certs in the Secret")
+	assert.Equal(t, caBundles[0], secret.Data["ca.crt"])
+}
+
+func TestWebhookRegistrarRenewGeneratedCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secrets := fake.NewSimpleClientset().CoreV1().Secrets("default")
+	wr, err := newWebhookRegistrar(nil, "k8s-ingress-claim", "default/k8s-ingress-claim", "CREATE", "Fail", "")
+	assert.NoError(t, err)
+
+	// certs expiring within the renewal window
+	caPEM, certPEM, keyPEM, err := util.GenerateCerts(wr.dnsNames(), time.Hour)
+	assert.NoError(t, err)
+	_, err = secrets.Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "k8s-ingress-claim-generated-certs"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":                caPEM,
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}, v1.CreateOptions{})
+	assert.NoError(t, err)
+
+	certFile, keyFile, err := wr.generateWebhookCerts(secrets, "k8s-ingress-claim-generated-certs", dir)
+	assert.NoError(t, err, "should renew the expiring certs")
+	secret, err := secrets.Get(context.TODO(), "k8s-ingress-claim-generated-certs", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, certPEM, secret.Data[corev1.TLSCertKey], "should renew the server cert in the Secret")
+	assert.False(t, wr.isExpiring(secret.Data[corev1.TLSCertKey]))
+	served, err := ioutil.ReadFile(certFile)
+	assert.NoError(t, err)
+	assert.Equal(t, secret.Data[corev1.TLSCertKey], served, "should rewrite the server cert file")
+	key, err := ioutil.ReadFile(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, secret.Data[corev1.TLSPrivateKeyKey], key, "should rewrite the server key file")
+
+	caBundle, err := wr.caBundle()
+	assert.NoError(t, err)
+	assert.Equal(t, secret.Data["ca.crt"], caBundle)
+	assert.True(t, bytes.HasSuffix(caBundle, caPEM), "should keep trusting the previous CA")
+	assert.NotEqual(t, caPEM, caBundle, "should trust the renewed CA")
+
+	// the renewed certs are loaded as they are
+	_, _, err = wr.loadGeneratedCerts()
+	assert.NoError(t, err)
+	reloaded, err := secrets.Get(context.TODO(), "k8s-ingress-claim-generated-certs", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, secret.Data, reloaded.Data, "should not renew the certs again")
+}